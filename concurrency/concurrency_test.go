@@ -0,0 +1,175 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+)
+
+func TestAcquireContextCancelled(t *testing.T) {
+	p := NewPrioritySemaphore(1)
+	p.Acquire(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.AcquireContext(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded; got %v", err)
+	}
+
+	// The cancelled waiter must not be handed the semaphore.
+	p.Release()
+	if !p.TryAcquire() {
+		t.Errorf("expected released semaphore to be free")
+	}
+
+	stats := p.Stats()
+	if stats.Acquired != 2 || stats.Cancelled != 1 || stats.Waiting != 0 || stats.MaxWaiting != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLockContextCancelled(t *testing.T) {
+	h := NewHashLocker()
+	hash := crypto.HashString("a")
+	h.Lock(hash)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.LockContext(ctx, hash); err != context.Canceled {
+		t.Fatalf("expected canceled; got %v", err)
+	}
+
+	h.Unlock(hash)
+	if !h.TryLock(hash) {
+		t.Errorf("expected unlocked hash to be free")
+	}
+}
+
+func TestLockBothContext(t *testing.T) {
+	p := NewPrioritySemaphore(1)
+	h := NewHashLocker()
+	hash := crypto.HashString("a")
+	h.Lock(hash)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := LockBothContext(ctx, p.LockFor(0), h.LockFor(hash)); err == nil {
+		t.Fatalf("expected error locking held hash")
+	}
+
+	// Neither lock may be held after giving up.
+	if !p.TryAcquire() {
+		t.Errorf("expected semaphore to be free")
+	}
+}
+
+func TestFairSchedulerSharesSlots(t *testing.T) {
+	s := NewFairScheduler(2)
+
+	// A slow peer holds both slots, and both peers queue up more work.
+	s.Acquire("slow", 0)
+	s.Acquire("slow", 0)
+
+	granted := make(chan string, 2)
+	for _, class := range []string{"slow", "fast"} {
+		go func(class string) {
+			s.Acquire(class, 0)
+			granted <- class
+		}(class)
+	}
+	for {
+		stats := s.Stats()
+		if stats["slow"].Waiting == 1 && stats["fast"].Waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The freed slot goes to the peer holding fewer slots.
+	s.Release("slow")
+	if class := <-granted; class != "fast" {
+		t.Errorf("expected fast to get the slot; got %s", class)
+	}
+	s.Release("slow")
+	if class := <-granted; class != "slow" {
+		t.Errorf("expected slow to get the slot; got %s", class)
+	}
+
+	stats := s.Stats()
+	if stats["slow"].Active != 1 || stats["fast"].Active != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestFairSchedulerWeights(t *testing.T) {
+	s := NewFairScheduler(3)
+	s.SetWeight("heavy", 2)
+
+	s.Acquire("heavy", 0)
+	s.Acquire("light", 0)
+	s.Acquire("other", 0)
+
+	// light queues first, so it would win a tie.
+	granted := make(chan string, 2)
+	for _, class := range []string{"light", "heavy"} {
+		go func(class string) {
+			s.Acquire(class, 0)
+			granted <- class
+		}(class)
+		for s.Stats()[class].Waiting != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Both hold one slot, but heavy's weight makes its share smaller.
+	s.Release("other")
+	if class := <-granted; class != "heavy" {
+		t.Errorf("expected heavy to get the slot; got %s", class)
+	}
+
+	s.Release("heavy")
+	if class := <-granted; class != "light" {
+		t.Errorf("expected light to get the slot; got %s", class)
+	}
+}
+
+func TestFairSchedulerTieBreak(t *testing.T) {
+	s := NewFairScheduler(3)
+	s.Acquire("a", 0)
+	s.Acquire("b", 0)
+	s.Acquire("c", 0)
+
+	// a queues before b, and then queues again at a higher priority, which
+	// puts its newer waiter in front.
+	granted := make(chan string, 3)
+	waits := []struct {
+		class    string
+		priority int
+	}{{"a", 0}, {"b", 0}, {"a", 1}}
+	for i, wait := range waits {
+		go func(class string, priority int) {
+			s.Acquire(class, priority)
+			granted <- class
+		}(wait.class, wait.priority)
+		for waiting := 0; waiting != i+1; {
+			stats := s.Stats()
+			waiting = stats["a"].Waiting + stats["b"].Waiting
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// a and b hold one slot each; a has waited longest.
+	s.Release("c")
+	if class := <-granted; class != "a" {
+		t.Errorf("expected a to get the slot; got %s", class)
+	}
+
+	// Releasing a slot of a class that holds none must not add capacity.
+	s.Release("unknown")
+	if s.TryAcquire("c") {
+		t.Errorf("expected no free slots")
+	}
+}
@@ -0,0 +1,223 @@
+package concurrency
+
+import (
+	"container/heap"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A FairScheduler is a semaphore shared by several classes of callers, such
+// as the peers a server fetches from. When a slot frees up, it goes to the
+// waiting class holding the fewest slots relative to its weight, so a class
+// whose slots are held for long cannot starve the others. Within a class,
+// waiters are served by priority as in PrioritySemaphore.
+type FairScheduler struct {
+	mu sync.Mutex
+
+	capacity int
+	classes  map[string]*fairClass
+}
+
+type fairClass struct {
+	weight  int
+	active  int
+	waiting queue
+	stats   WaitStats
+}
+
+// ClassStats describes one class of a FairScheduler.
+type ClassStats struct {
+	Weight int
+	Active int // slots held
+	WaitStats
+}
+
+func NewFairScheduler(capacity int) *FairScheduler {
+	return &FairScheduler{
+		capacity: capacity,
+		classes:  make(map[string]*fairClass),
+	}
+}
+
+// class must be called holding s.mu.
+func (s *FairScheduler) class(name string) *fairClass {
+	c := s.classes[name]
+	if c == nil {
+		c = &fairClass{weight: 1}
+		s.classes[name] = c
+	}
+	return c
+}
+
+// SetWeight sets the share of slots class gets when others are waiting too.
+// Classes have weight 1 until set; weights below 1 count as 1.
+func (s *FairScheduler) SetWeight(class string, weight int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if weight < 1 {
+		weight = 1
+	}
+	s.class(class).weight = weight
+}
+
+func (s *FairScheduler) Acquire(class string, priority int) {
+	s.AcquireContext(context.Background(), class, priority)
+}
+
+// AcquireContext acquires a slot for class, but gives up when ctx is done,
+// leaving the queue. It returns nil iff it acquired a slot.
+func (s *FairScheduler) AcquireContext(ctx context.Context, class string, priority int) error {
+	s.mu.Lock()
+	c := s.class(class)
+	if s.capacity > 0 {
+		s.capacity -= 1
+		c.active += 1
+		c.stats.Acquired += 1
+		s.mu.Unlock()
+		return nil
+	}
+
+	pending := &pending{priority: priority, c: make(chan struct{}), start: time.Now()}
+	heap.Push(&c.waiting, pending)
+	c.stats.enqueue()
+	s.mu.Unlock()
+
+	select {
+	case <-pending.c:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pending.index == -1 {
+		// release granted us a slot before we noticed; pass it on.
+		c.stats.Acquired -= 1
+		c.stats.Waited -= 1
+		c.stats.Cancelled += 1
+		s.release(c)
+	} else {
+		heap.Remove(&c.waiting, pending.index)
+		c.stats.dequeue(pending.start, true)
+	}
+	return ctx.Err()
+}
+
+func (s *FairScheduler) Release(class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.classes[class]
+	if c == nil || c.active == 0 {
+		log.Printf("release of fair scheduler slot not held by %s\n", class)
+		return
+	}
+	s.release(c)
+}
+
+// oldest returns when the longest waiting caller of c started waiting. The
+// queue is ordered by priority, so that need not be the first one.
+func (c *fairClass) oldest() time.Time {
+	oldest := c.waiting[0].start
+	for _, pending := range c.waiting[1:] {
+		if pending.start.Before(oldest) {
+			oldest = pending.start
+		}
+	}
+	return oldest
+}
+
+// release frees a slot held by c, and hands it to the next waiter. Must be
+// called holding s.mu.
+func (s *FairScheduler) release(c *fairClass) {
+	c.active -= 1
+
+	var next *fairClass
+	for _, candidate := range s.classes {
+		if len(candidate.waiting) == 0 {
+			continue
+		}
+		// Compare active/weight without dividing; break ties in favor of
+		// whoever has waited longest.
+		if next == nil {
+			next = candidate
+			continue
+		}
+		a, b := candidate.active*next.weight, next.active*candidate.weight
+		if a < b || (a == b && candidate.oldest().Before(next.oldest())) {
+			next = candidate
+		}
+	}
+
+	if next == nil {
+		s.capacity += 1
+		return
+	}
+
+	pending := heap.Pop(&next.waiting).(*pending)
+	next.active += 1
+	next.stats.dequeue(pending.start, false)
+	close(pending.c)
+}
+
+func (s *FairScheduler) TryAcquire(class string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.capacity > 0 {
+		c := s.class(class)
+		s.capacity -= 1
+		c.active += 1
+		c.stats.Acquired += 1
+		return true
+	}
+	return false
+}
+
+func (s *FairScheduler) Stats() map[string]ClassStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]ClassStats)
+	for name, c := range s.classes {
+		stats[name] = ClassStats{
+			Weight:    c.weight,
+			Active:    c.active,
+			WaitStats: c.stats,
+		}
+	}
+	return stats
+}
+
+type fairSchedulerLock struct {
+	s        *FairScheduler
+	class    string
+	priority int
+}
+
+func (l *fairSchedulerLock) Lock() {
+	l.s.Acquire(l.class, l.priority)
+}
+
+func (l *fairSchedulerLock) LockContext(ctx context.Context) error {
+	return l.s.AcquireContext(ctx, l.class, l.priority)
+}
+
+func (l *fairSchedulerLock) Unlock() {
+	l.s.Release(l.class)
+}
+
+func (l *fairSchedulerLock) TryLock() bool {
+	return l.s.TryAcquire(l.class)
+}
+
+func (s *FairScheduler) LockFor(class string, priority int) TryLock {
+	return &fairSchedulerLock{
+		s:        s,
+		class:    class,
+		priority: priority,
+	}
+}
@@ -2,18 +2,28 @@ package concurrency
 
 import (
 	"sync"
+	"time"
+
+	"golang.org/x/net/context"
 
 	"github.com/jellevandenhooff/keytree/crypto"
 )
 
 type hashLock struct {
-	mu   sync.Mutex
+	c    chan struct{} // holds a value while locked
 	refs int
 }
 
+func newHashLock() *hashLock {
+	return &hashLock{
+		c: make(chan struct{}, 1),
+	}
+}
+
 type HashLocker struct {
 	mu    sync.Mutex
 	locks map[crypto.Hash]*hashLock
+	stats WaitStats
 }
 
 func NewHashLocker() *HashLocker {
@@ -23,16 +33,53 @@ func NewHashLocker() *HashLocker {
 }
 
 func (h *HashLocker) Lock(hash crypto.Hash) {
+	h.LockContext(context.Background(), hash)
+}
+
+// LockContext locks hash like Lock, but gives up when ctx is done. It returns
+// nil iff it acquired the lock.
+func (h *HashLocker) LockContext(ctx context.Context, hash crypto.Hash) error {
 	h.mu.Lock()
 	l := h.locks[hash]
 	if l == nil {
-		l = new(hashLock)
+		l = newHashLock()
 		h.locks[hash] = l
 	}
 	l.refs += 1
+
+	select {
+	case l.c <- struct{}{}:
+		h.stats.Acquired += 1
+		h.mu.Unlock()
+		return nil
+	default:
+	}
+	h.stats.enqueue()
 	h.mu.Unlock()
 
-	l.mu.Lock()
+	start := time.Now()
+	select {
+	case l.c <- struct{}{}:
+		h.mu.Lock()
+		h.stats.dequeue(start, false)
+		h.mu.Unlock()
+		return nil
+
+	case <-ctx.Done():
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.stats.dequeue(start, true)
+		h.release(hash, l)
+		return ctx.Err()
+	}
+}
+
+// release drops a reference to l. Must be called holding h.mu.
+func (h *HashLocker) release(hash crypto.Hash, l *hashLock) {
+	l.refs -= 1
+	if l.refs == 0 {
+		delete(h.locks, hash)
+	}
 }
 
 func (h *HashLocker) Unlock(hash crypto.Hash) {
@@ -40,12 +87,8 @@ func (h *HashLocker) Unlock(hash crypto.Hash) {
 	defer h.mu.Unlock()
 
 	l := h.locks[hash]
-	l.mu.Unlock()
-
-	l.refs -= 1
-	if l.refs == 0 {
-		delete(h.locks, hash)
-	}
+	<-l.c
+	h.release(hash, l)
 }
 
 func (h *HashLocker) TryLock(hash crypto.Hash) bool {
@@ -56,14 +99,23 @@ func (h *HashLocker) TryLock(hash crypto.Hash) bool {
 		return false
 	}
 
-	l := new(hashLock)
+	l := newHashLock()
 	h.locks[hash] = l
 	l.refs += 1
-	l.mu.Lock()
+	l.c <- struct{}{}
+	h.stats.Acquired += 1
 
 	return true
 }
 
+// Stats returns wait statistics summed over all hashes.
+func (h *HashLocker) Stats() WaitStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.stats
+}
+
 type hashLockerLock struct {
 	h    *HashLocker
 	hash crypto.Hash
@@ -73,6 +125,10 @@ func (l *hashLockerLock) Lock() {
 	l.h.Lock(l.hash)
 }
 
+func (l *hashLockerLock) LockContext(ctx context.Context) error {
+	return l.h.LockContext(ctx, l.hash)
+}
+
 func (l *hashLockerLock) Unlock() {
 	l.h.Unlock(l.hash)
 }
@@ -3,27 +3,39 @@ package concurrency
 import (
 	"container/heap"
 	"sync"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
 type pending struct {
 	priority int
 	c        chan struct{}
+	start    time.Time
+	index    int // position in the queue, or -1 once granted
 }
 
 type queue []*pending
 
 func (q queue) Len() int           { return len(q) }
 func (q queue) Less(a, b int) bool { return q[a].priority > q[b].priority }
-func (q queue) Swap(a, b int)      { q[a], q[b] = q[b], q[a] }
+func (q queue) Swap(a, b int) {
+	q[a], q[b] = q[b], q[a]
+	q[a].index = a
+	q[b].index = b
+}
 
 func (q *queue) Push(x interface{}) {
-	*q = append(*q, x.(*pending))
+	pending := x.(*pending)
+	pending.index = len(*q)
+	*q = append(*q, pending)
 }
 
 func (q *queue) Pop() interface{} {
 	old := *q
 	n := len(old)
 	x := old[n-1]
+	x.index = -1
 	*q = old[0 : n-1]
 	return x
 }
@@ -33,6 +45,7 @@ type PrioritySemaphore struct {
 
 	capacity int
 	waiting  queue
+	stats    WaitStats
 }
 
 func NewPrioritySemaphore(capacity int) *PrioritySemaphore {
@@ -42,25 +55,57 @@ func NewPrioritySemaphore(capacity int) *PrioritySemaphore {
 }
 
 func (p *PrioritySemaphore) Acquire(priority int) {
+	p.AcquireContext(context.Background(), priority)
+}
+
+// AcquireContext acquires like Acquire, but gives up when ctx is done, leaving
+// the queue. It returns nil iff it acquired the semaphore.
+func (p *PrioritySemaphore) AcquireContext(ctx context.Context, priority int) error {
 	p.mu.Lock()
 	if p.capacity > 0 {
 		p.capacity -= 1
+		p.stats.Acquired += 1
 		p.mu.Unlock()
-		return
+		return nil
 	}
 
-	c := make(chan struct{})
-	heap.Push(&p.waiting, &pending{priority: priority, c: c})
+	pending := &pending{priority: priority, c: make(chan struct{}), start: time.Now()}
+	heap.Push(&p.waiting, pending)
+	p.stats.enqueue()
 	p.mu.Unlock()
 
-	<-c
+	select {
+	case <-pending.c:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pending.index == -1 {
+		// Release granted us the semaphore before we noticed; pass it on.
+		p.stats.Acquired -= 1
+		p.stats.Waited -= 1
+		p.stats.Cancelled += 1
+		p.release()
+	} else {
+		heap.Remove(&p.waiting, pending.index)
+		p.stats.dequeue(pending.start, true)
+	}
+	return ctx.Err()
 }
 
 func (p *PrioritySemaphore) Release() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.release()
+}
+
+// release must be called holding p.mu.
+func (p *PrioritySemaphore) release() {
 	if len(p.waiting) > 0 {
 		pending := heap.Pop(&p.waiting).(*pending)
+		p.stats.dequeue(pending.start, false)
 		close(pending.c)
 	} else {
 		p.capacity += 1
@@ -72,11 +117,19 @@ func (p *PrioritySemaphore) TryAcquire() bool {
 	defer p.mu.Unlock()
 	if p.capacity > 0 {
 		p.capacity -= 1
+		p.stats.Acquired += 1
 		return true
 	}
 	return false
 }
 
+func (p *PrioritySemaphore) Stats() WaitStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
 type prioritySemaphoreLock struct {
 	p        *PrioritySemaphore
 	priority int
@@ -86,6 +139,10 @@ func (l *prioritySemaphoreLock) Lock() {
 	l.p.Acquire(l.priority)
 }
 
+func (l *prioritySemaphoreLock) LockContext(ctx context.Context) error {
+	return l.p.AcquireContext(ctx, l.priority)
+}
+
 func (l *prioritySemaphoreLock) Unlock() {
 	l.p.Release()
 }
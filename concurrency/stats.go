@@ -0,0 +1,37 @@
+package concurrency
+
+import (
+	"time"
+)
+
+// WaitStats describes how long callers waited for a lock, and how many are
+// waiting now.
+type WaitStats struct {
+	Acquired  uint64        // acquisitions, including those that did not wait
+	Waited    uint64        // acquisitions that had to wait
+	Cancelled uint64        // waits abandoned because their context was done
+	WaitTime  time.Duration // total time spent waiting, including cancelled waits
+
+	Waiting    int // current queue depth
+	MaxWaiting int // deepest queue so far
+}
+
+// The helpers below must be called holding the owning lock's mutex.
+
+func (s *WaitStats) enqueue() {
+	s.Waiting += 1
+	if s.Waiting > s.MaxWaiting {
+		s.MaxWaiting = s.Waiting
+	}
+}
+
+func (s *WaitStats) dequeue(start time.Time, cancelled bool) {
+	s.Waiting -= 1
+	s.WaitTime += time.Since(start)
+	if cancelled {
+		s.Cancelled += 1
+	} else {
+		s.Acquired += 1
+		s.Waited += 1
+	}
+}
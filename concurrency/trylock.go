@@ -1,21 +1,38 @@
 package concurrency
 
+import (
+	"golang.org/x/net/context"
+)
+
 type TryLock interface {
 	Lock()
+	// LockContext locks like Lock, but gives up when ctx is done. It returns
+	// nil iff it acquired the lock.
+	LockContext(ctx context.Context) error
 	Unlock()
 	TryLock() bool
 }
 
 func LockBoth(a, b TryLock) {
+	LockBothContext(context.Background(), a, b)
+}
+
+// LockBothContext locks a and b like LockBoth, but gives up when ctx is done.
+// It returns nil iff it acquired both locks; otherwise it holds neither.
+func LockBothContext(ctx context.Context, a, b TryLock) error {
 	for {
-		a.Lock()
+		if err := a.LockContext(ctx); err != nil {
+			return err
+		}
 		if b.TryLock() {
-			return
+			return nil
 		}
 		a.Unlock()
-		b.Lock()
+		if err := b.LockContext(ctx); err != nil {
+			return err
+		}
 		if a.TryLock() {
-			return
+			return nil
 		}
 		b.Unlock()
 	}
@@ -133,6 +133,27 @@ func Verify(publicKey string, signable Signable, signature string) error {
 	return nil
 }
 
+// PublicKeyForPrivateKey returns the public key belonging to an ed25519
+// private key.
+func PublicKeyForPrivateKey(privateKey string) (string, error) {
+	var key [ed25519.PrivateKeySize]byte
+	if err := unwrapFixed(privateKey, "ed25519-priv", key[:]); err != nil {
+		return "", err
+	}
+	// The second half of an ed25519 private key is its public key.
+	return wrap(key[32:], "ed25519-pub"), nil
+}
+
+func CheckEd25519PublicKey(publicKey string) error {
+	var key [ed25519.PublicKeySize]byte
+	return unwrapFixed(publicKey, "ed25519-pub", key[:])
+}
+
+func CheckBoxPublicKey(publicKey string) error {
+	var key [32]byte
+	return unwrapFixed(publicKey, "box-pub", key[:])
+}
+
 func Encrypt(message, public, private string) (string, error) {
 	var nonce [24]byte
 	mustRandomReader.Read(nonce[:])
@@ -0,0 +1,85 @@
+package dkimproof
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/names"
+)
+
+// Config describes where and how a Server receives mail.
+type Config struct {
+	// Domain at which verification addresses are handed out.
+	Domain string
+	// Other domains mail is accepted for, such as a previous Domain.
+	ExtraDomains []string `json:",omitempty"`
+
+	ListenAddr string
+
+	// Certificate and key files for STARTTLS; if empty, STARTTLS is not
+	// offered.
+	TLSCertFile string `json:",omitempty"`
+	TLSKeyFile  string `json:",omitempty"`
+
+	// Certificates of authorities trusted to issue S/MIME certificates, in
+	// PEM; if empty, S/MIME signatures are not accepted.
+	SMIMETrustStoreFile string `json:",omitempty"`
+
+	MaxMessageSize int64 // per message, in bytes
+	MaxConnections int   // concurrent SMTP connections
+
+	CommandTimeoutSeconds int // to receive and handle a command or message
+	IdleTimeoutSeconds    int // to wait for the next command
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Domain:         "keytree.io",
+		ListenAddr:     ":smtp",
+		MaxMessageSize: 1 << 20,
+		MaxConnections: 100,
+
+		CommandTimeoutSeconds: 120,
+		IdleTimeoutSeconds:    300,
+	}
+}
+
+func (c *Config) Check() error {
+	if _, err := names.NormalizeDomain(c.Domain); err != nil {
+		return err
+	}
+	for _, domain := range c.ExtraDomains {
+		if _, err := names.NormalizeDomain(domain); err != nil {
+			return err
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("need both a tls certificate and key")
+	}
+	if c.MaxMessageSize <= 0 || c.MaxConnections <= 0 {
+		return errors.New("message size and connection limits must be positive")
+	}
+	if c.CommandTimeoutSeconds <= 0 || c.IdleTimeoutSeconds <= 0 {
+		return errors.New("smtp timeouts must be positive")
+	}
+	return nil
+}
+
+// pendingAddress maps a recipient address at any of the receiving domains to
+// the address handed out at Domain, or returns "" if mail for it is not
+// accepted.
+func (c *Config) pendingAddress(to string) string {
+	to = strings.ToLower(to)
+	at := strings.LastIndex(to, "@")
+	if at == -1 {
+		return ""
+	}
+	local, domain := to[:at], to[at+1:]
+
+	for _, accepted := range append([]string{c.Domain}, c.ExtraDomains...) {
+		if strings.EqualFold(domain, accepted) {
+			return local + "@" + strings.ToLower(c.Domain)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package dkimproof
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/encoding/base32"
+)
+
+// A MailStore keeps received emails on disk so that failed verifications can
+// be diagnosed. Messages are removed once they are older than maxAge, or
+// when there are more than maxMessages. A MailStore is threadsafe.
+type MailStore struct {
+	dir         string
+	maxAge      time.Duration
+	maxMessages int
+}
+
+func NewMailStore(dir string, maxAge time.Duration, maxMessages int) (*MailStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &MailStore{
+		dir:         dir,
+		maxAge:      maxAge,
+		maxMessages: maxMessages,
+	}, nil
+}
+
+func MailID(mail string) string {
+	return base32.EncodeToString(crypto.HashString(mail).Bytes()[:8])
+}
+
+func checkMailID(id string) error {
+	if _, err := base32.DecodeString(id); err != nil || id == "" {
+		return errors.New("bad mail id")
+	}
+	return nil
+}
+
+// Store writes mail to disk and returns its ID.
+func (s *MailStore) Store(mail string) (string, error) {
+	id := MailID(mail)
+	if err := ioutil.WriteFile(filepath.Join(s.dir, id), []byte(mail), 0600); err != nil {
+		return "", err
+	}
+
+	if err := s.prune(); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (s *MailStore) Read(id string) (string, error) {
+	if err := checkMailID(id); err != nil {
+		return "", err
+	}
+
+	bytes, err := ioutil.ReadFile(filepath.Join(s.dir, id))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+type byModTime []os.FileInfo
+
+func (b byModTime) Len() int           { return len(b) }
+func (b byModTime) Less(i, j int) bool { return b[i].ModTime().Before(b[j].ModTime()) }
+func (b byModTime) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func (s *MailStore) prune() error {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	sort.Sort(byModTime(infos))
+
+	cutoff := time.Now().Add(-s.maxAge)
+	excess := len(infos) - s.maxMessages
+
+	for i, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		if (s.maxMessages > 0 && i < excess) || (s.maxAge > 0 && info.ModTime().Before(cutoff)) {
+			if err := os.Remove(filepath.Join(s.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package dkimproof
+
+import (
+	"sync"
+)
+
+// A notifier wakes up pollers waiting for a pending verification to change.
+// A notifier is threadsafe.
+type notifier struct {
+	mu      sync.Mutex
+	changed map[string]chan struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		changed: make(map[string]chan struct{}),
+	}
+}
+
+// wait returns a channel that is closed after the next notify for email. Get
+// the channel before reading the state waited on, so no change is missed.
+func (n *notifier) wait(email string) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ch, found := n.changed[email]
+	if !found {
+		ch = make(chan struct{})
+		n.changed[email] = ch
+	}
+	return ch
+}
+
+func (n *notifier) notify(email string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if ch, found := n.changed[email]; found {
+		close(ch)
+		delete(n.changed, email)
+	}
+}
+
+// notifyAll wakes up all pollers, so channels for verifications that never
+// change are not kept forever.
+func (n *notifier) notifyAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for email, ch := range n.changed {
+		close(ch)
+		delete(n.changed, email)
+	}
+}
@@ -0,0 +1,63 @@
+package dkimproof
+
+import (
+	"sync"
+
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// A PendingStore holds in-flight verifications, keyed by the address the
+// email should be sent to. A PendingStore must be threadsafe.
+type PendingStore interface {
+	// ReadPending returns nil if there is no verification for email.
+	ReadPending(email string) (*wire.DKIMUpdate, error)
+	WritePending(email string, update *wire.DKIMUpdate) error
+	// ExpirePending removes all verifications that expired before now.
+	ExpirePending(now uint64) error
+}
+
+type memoryPendingStore struct {
+	mu      sync.Mutex
+	pending map[string]*wire.DKIMUpdate
+}
+
+// NewMemoryPendingStore returns a PendingStore that loses its contents on
+// restart.
+func NewMemoryPendingStore() PendingStore {
+	return &memoryPendingStore{
+		pending: make(map[string]*wire.DKIMUpdate),
+	}
+}
+
+func (s *memoryPendingStore) ReadPending(email string) (*wire.DKIMUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update, found := s.pending[email]
+	if !found {
+		return nil, nil
+	}
+	copied := *update
+	return &copied, nil
+}
+
+func (s *memoryPendingStore) WritePending(email string, update *wire.DKIMUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *update
+	s.pending[email] = &copied
+	return nil
+}
+
+func (s *memoryPendingStore) ExpirePending(now uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.pending {
+		if v.Expiration < now {
+			delete(s.pending, k)
+		}
+	}
+	return nil
+}
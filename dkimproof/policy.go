@@ -0,0 +1,144 @@
+package dkimproof
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// A Policy restricts which DKIM signatures are good enough to prove ownership
+// of an address.
+type Policy struct {
+	MinRSABits        int
+	AllowedAlgorithms []string
+	// Headers that must be covered by the signature.
+	RequiredHeaders []string
+	// Allow signatures with an l= tag, which cover only part of the body.
+	AllowBodyLength bool
+	// Reject keys from DNSSEC-signed zones that did not validate. Only has
+	// an effect if the server validates DNSSEC.
+	RequireDNSSEC bool `json:",omitempty"`
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinRSABits:        1024,
+		AllowedAlgorithms: []string{"rsa-sha256", "ed25519-sha256"},
+		RequiredHeaders:   []string{"from", "subject"},
+		AllowBodyLength:   false,
+	}
+}
+
+func (p *Policy) Digest() crypto.Hash {
+	if p == nil {
+		p = DefaultPolicy()
+	}
+
+	h := crypto.NewHasher()
+	h.WriteUint64(uint64(p.MinRSABits))
+	h.WriteUint64(uint64(len(p.AllowedAlgorithms)))
+	for _, algorithm := range p.AllowedAlgorithms {
+		h.WriteString(algorithm)
+	}
+	h.WriteUint64(uint64(len(p.RequiredHeaders)))
+	for _, header := range p.RequiredHeaders {
+		h.WriteString(header)
+	}
+	h.WriteBool(p.AllowBodyLength)
+	h.WriteBool(p.RequireDNSSEC)
+	return h.Sum()
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTags parses the tag=value pairs of a DKIM key record or signature
+// header. Whitespace within values is dropped.
+func parseTags(records []string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(strings.Join(records, ""), ";") {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		tags[strings.TrimSpace(parts[0])] = strings.Join(strings.Fields(parts[1]), "")
+	}
+	return tags
+}
+
+func rsaKeyBits(key *wire.DKIMKey) (int, error) {
+	tags := parseTags(key.Records)
+	if k, found := tags["k"]; found && k != "rsa" {
+		return 0, fmt.Errorf("key type '%s' is not rsa", k)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(tags["p"])
+	if err != nil {
+		return 0, fmt.Errorf("bad public key: %s", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		// Some domains publish bare PKCS#1 keys.
+		if rsaKey, err := x509.ParsePKCS1PublicKey(der); err == nil {
+			return rsaKey.N.BitLen(), nil
+		}
+		return 0, fmt.Errorf("bad public key: %s", err)
+	}
+	rsaKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return 0, errors.New("public key is not rsa")
+	}
+	return rsaKey.N.BitLen(), nil
+}
+
+// Check verifies that email, verified by key, satisfies the policy. A nil
+// policy is the default policy.
+func (p *Policy) Check(email *VerifiedEmail, key *wire.DKIMKey) error {
+	if p == nil {
+		p = DefaultPolicy()
+	}
+	algorithm := email.Tags["a"]
+
+	if !containsFold(p.AllowedAlgorithms, algorithm) {
+		return fmt.Errorf("dkim algorithm '%s' not allowed", algorithm)
+	}
+
+	if strings.HasPrefix(strings.ToLower(algorithm), "rsa-") {
+		bits, err := rsaKeyBits(key)
+		if err != nil {
+			return err
+		}
+		if bits < p.MinRSABits {
+			return fmt.Errorf("dkim key has %d bits, need at least %d", bits, p.MinRSABits)
+		}
+	}
+
+	headers := strings.Split(email.Tags["h"], ":")
+	for _, header := range p.RequiredHeaders {
+		if !containsFold(headers, header) {
+			return fmt.Errorf("dkim signature does not cover '%s' header", header)
+		}
+	}
+
+	if p.RequireDNSSEC && key.DNSSECSigned && !key.DNSSECValidated {
+		return errors.New("dkim key is dnssec signed but did not validate")
+	}
+
+	if _, found := email.Tags["l"]; found && !p.AllowBodyLength {
+		return errors.New("dkim signature has body length limit")
+	}
+
+	return nil
+}
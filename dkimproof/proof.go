@@ -2,11 +2,16 @@ package dkimproof
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/jellevandenhooff/dkim"
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/dns"
+	"github.com/jellevandenhooff/keytree/names"
+	"github.com/jellevandenhooff/keytree/unixtime"
 	"github.com/jellevandenhooff/keytree/wire"
 )
 
@@ -18,9 +23,13 @@ func init() {
 	for _, c := range []byte(mailCharacters) {
 		mailAllowed[c] = true
 	}
+	// Allow UTF-8 encoded internationalized addresses.
+	for c := 0x80; c < 256; c++ {
+		mailAllowed[c] = true
+	}
 }
 
-func extractFromAddress(email *dkim.VerifiedEmail) (string, error) {
+func extractFromAddress(email *VerifiedEmail) (string, error) {
 	headers := email.ExtractHeader("from")
 	if len(headers) != 1 {
 		return "", errors.New("expected exactly one from header")
@@ -35,7 +44,7 @@ func extractFromAddress(email *dkim.VerifiedEmail) (string, error) {
 			word = append(word, c)
 		} else {
 			if bytes.IndexByte(word, '@') != -1 {
-				addresses = append(addresses, strings.ToLower(string(word)))
+				addresses = append(addresses, string(word))
 			}
 			word = nil
 		}
@@ -44,15 +53,23 @@ func extractFromAddress(email *dkim.VerifiedEmail) (string, error) {
 	if len(addresses) != 1 {
 		return "", errors.New("expected exactly one email address in from header")
 	}
-	address := addresses[0]
+	address, err := names.NormalizeEmail(addresses[0])
+	if err != nil {
+		return "", err
+	}
 
-	if !strings.HasSuffix(address, "@"+email.Signature.Domain) {
+	domain, err := names.NormalizeDomain(email.Domain)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(address, "@"+domain) {
 		return "", errors.New("address in from header is not from signature's domain")
 	}
 	return address, nil
 }
 
-func CheckVerifiedEmail(email *dkim.VerifiedEmail, statement *wire.DKIMStatement) error {
+func CheckVerifiedEmail(email *VerifiedEmail, statement *wire.DKIMStatement) error {
 	from, err := extractFromAddress(email)
 	if err != nil {
 		return fmt.Errorf("could next extract sender: %s", err)
@@ -76,9 +93,150 @@ func CheckVerifiedEmail(email *dkim.VerifiedEmail, statement *wire.DKIMStatement
 	return nil
 }
 
-func CheckPlainEmail(mail string, statement *wire.DKIMStatement, dnsClient dkim.DNSClient) error {
-	email, err := dkim.ParseAndVerify(mail, dkim.HeadersOnly, dnsClient)
+// A recordingDNSClient remembers the answers it passed on, so that the key
+// used to verify an email can be archived with its proof.
+type recordingDNSClient struct {
+	client dkim.DNSClient
+	keys   map[string]*wire.DKIMKey
+}
+
+func (c *recordingDNSClient) record(key *wire.DKIMKey) {
+	if c.keys == nil {
+		c.keys = make(map[string]*wire.DKIMKey)
+	}
+	c.keys[strings.ToLower(trimDot(key.Hostname))] = key
+}
+
+func (c *recordingDNSClient) LookupTxt(hostname string) ([]string, error) {
+	// Resolvers that report DNSSEC outcomes let us record those too.
+	resolver, ok := c.client.(dns.TxtResolver)
+	if !ok {
+		records, err := c.client.LookupTxt(hostname)
+		if err == nil {
+			c.record(&wire.DKIMKey{Hostname: hostname, Records: records})
+		}
+		return records, err
+	}
+
+	result, err := resolver.ResolveTxt(hostname)
+	if err != nil {
+		return nil, err
+	}
+	if result.NXDomain {
+		return nil, dns.ErrNXDomain
+	}
+	c.record(&wire.DKIMKey{
+		Hostname:        hostname,
+		Records:         result.Records,
+		DNSSECSigned:    result.Signed,
+		DNSSECValidated: result.Validated,
+	})
+	return result.Records, nil
+}
+
+// An archivedDNSClient answers only with an archived key.
+type archivedDNSClient struct {
+	key *wire.DKIMKey
+}
+
+func trimDot(hostname string) string {
+	return strings.TrimSuffix(hostname, ".")
+}
+
+// ResolveTxt reports the archived DNSSEC outcome along with the key.
+func (c *archivedDNSClient) ResolveTxt(hostname string) (*dns.TxtResult, error) {
+	if !strings.EqualFold(trimDot(hostname), trimDot(c.key.Hostname)) {
+		return nil, fmt.Errorf("archived key is for '%s', not '%s'", c.key.Hostname, hostname)
+	}
+	return &dns.TxtResult{
+		Records:   c.key.Records,
+		Signed:    c.key.DNSSECSigned,
+		Validated: c.key.DNSSECValidated,
+	}, nil
+}
+
+func (c *archivedDNSClient) LookupTxt(hostname string) ([]string, error) {
+	result, err := c.ResolveTxt(hostname)
+	if err != nil {
+		return nil, err
+	}
+	return result.Records, nil
+}
+
+// VerifyAndRecordKey verifies the headers of mail, and also returns the DNS
+// key that verified it.
+func VerifyAndRecordKey(mail string, dnsClient dkim.DNSClient) (*VerifiedEmail, *wire.DKIMKey, error) {
+	recorder := &recordingDNSClient{client: dnsClient}
+	email, err := verifyEmail(mail, recorder)
 	if err != nil {
+		return nil, nil, err
+	}
+
+	key, found := recorder.keys[strings.ToLower(email.Tags["s"]+"._domainkey."+trimDot(email.Domain))]
+	if !found {
+		return nil, nil, errors.New("could not find key for verified dkim signature")
+	}
+	key.Timestamp = unixtime.Now()
+	return email, key, nil
+}
+
+func EncodeProof(proof *wire.DKIMProof) (string, error) {
+	bytes, err := json.Marshal(proof)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// DecodeProof decodes a dkim signature value. Values that are not JSON are
+// plain canonical headers, as produced before keys were archived.
+func DecodeProof(signature string) (*wire.DKIMProof, error) {
+	if !strings.HasPrefix(signature, "{") {
+		return &wire.DKIMProof{Headers: signature}, nil
+	}
+
+	var proof *wire.DKIMProof
+	if err := json.Unmarshal([]byte(signature), &proof); err != nil {
+		return nil, err
+	}
+	if err := proof.Check(); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// CheckProof verifies that signature is an encoded proof for statement. By
+// default, the email is checked against the key currently in DNS. With
+// archived set, it is instead checked against the key in the proof if that key
+// is attested by one of the trusted server keys; this keeps
+// historical updates verifiable after a domain rotates its keys. Either way,
+// the signature must satisfy policy.
+func CheckProof(signature string, statement *wire.DKIMStatement, policy *Policy, dnsClient dkim.DNSClient, trusted map[string]bool, archived bool) error {
+	proof, err := DecodeProof(signature)
+	if err != nil {
+		return fmt.Errorf("could not decode dkim proof: %s", err)
+	}
+
+	// Keys attested by servers we don't trust are no better than none; the
+	// email may still verify against the key in DNS.
+	if !archived || proof.Key == nil || !trusted[proof.Key.PublicKey] {
+		return CheckPlainEmail(proof.Headers, statement, policy, dnsClient)
+	}
+
+	if err := crypto.Verify(proof.Key.PublicKey, proof.Key.Key, proof.Key.Signature); err != nil {
+		return err
+	}
+
+	return CheckPlainEmail(proof.Headers, statement, policy, &archivedDNSClient{key: proof.Key.Key})
+}
+
+func CheckPlainEmail(mail string, statement *wire.DKIMStatement, policy *Policy, dnsClient dkim.DNSClient) error {
+	email, key, err := VerifyAndRecordKey(mail, dnsClient)
+	if err != nil {
+		return err
+	}
+
+	if err := policy.Check(email, key); err != nil {
 		return err
 	}
 
@@ -0,0 +1,214 @@
+package dkimproof
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/dns"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func TestDecodeProof(t *testing.T) {
+	proof, err := DecodeProof("from:a@example.com\r\n")
+	if err != nil || proof.Key != nil || proof.Headers != "from:a@example.com\r\n" {
+		t.Errorf("legacy proof decoded as %v, %v", proof, err)
+	}
+
+	encoded, err := EncodeProof(&wire.DKIMProof{
+		Headers: "from:a@example.com\r\n",
+		Key: &wire.SignedDKIMKey{
+			Key: &wire.DKIMKey{
+				Hostname: "sel._domainkey.example.com.",
+				Records:  []string{"v=DKIM1; p=abc"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err = DecodeProof(encoded)
+	if err != nil || proof.Key == nil || proof.Key.Key.Hostname != "sel._domainkey.example.com." {
+		t.Errorf("proof decoded as %v, %v", proof, err)
+	}
+}
+
+func TestArchivedDNSClient(t *testing.T) {
+	client := &archivedDNSClient{key: &wire.DKIMKey{
+		Hostname: "sel._domainkey.example.com.",
+		Records:  []string{"v=DKIM1; p=abc"},
+	}}
+
+	if records, err := client.LookupTxt("Sel._domainkey.example.com"); err != nil || len(records) != 1 {
+		t.Errorf("lookup of archived hostname returned %v, %v", records, err)
+	}
+	if _, err := client.LookupTxt("sel._domainkey.example.org."); err == nil {
+		t.Errorf("lookup of other hostname succeeded")
+	}
+}
+
+func TestRSAKeyBits(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(der)
+
+	// Records may be split into several strings at arbitrary points.
+	bits, err := rsaKeyBits(&wire.DKIMKey{
+		Records: []string{"v=DKIM1; k=rsa; p=" + encoded[:20], encoded[20:]},
+	})
+	if err != nil || bits != 1024 {
+		t.Errorf("rsaKeyBits returned %d, %v", bits, err)
+	}
+
+	if _, err := rsaKeyBits(&wire.DKIMKey{Records: []string{"v=DKIM1; k=ed25519; p=abc"}}); err == nil {
+		t.Errorf("rsaKeyBits accepted an ed25519 key")
+	}
+}
+
+// readMail reads a captured email from testdata. The files are stored with
+// plain newlines for readability; mail on the wire uses CRLF.
+func readMail(t *testing.T, name string) string {
+	bytes, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Replace(string(bytes), "\n", "\r\n", -1)
+}
+
+// The captured email was sent from Gmail to keytree.io in March 2015; the
+// ed25519 email is the example from RFC 8463 appendix A.3, without its second,
+// rsa-sha256 signature.
+var (
+	capturedStatement = &wire.DKIMStatement{
+		Sender: "jelle@vandenhooff.name",
+		Token:  "vnsy7km1hn4crbyp0h32m3932p38qtgbhpxf9mp01s6w40mvk2jg",
+	}
+	ed25519Statement = &wire.DKIMStatement{
+		Sender: "joe@football.example.com",
+		Token:  "dinner",
+	}
+)
+
+func loadFixture(t *testing.T) *dns.ReplayClient {
+	fixture, err := dns.LoadFixture(filepath.Join("testdata", "dns.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dns.NewReplayClient(fixture)
+}
+
+func TestCheckPlainEmail(t *testing.T) {
+	client := loadFixture(t)
+
+	captured := readMail(t, "captured.eml")
+	if err := CheckPlainEmail(captured, capturedStatement, DefaultPolicy(), client); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := CheckPlainEmail(readMail(t, "ed25519.eml"), ed25519Statement, DefaultPolicy(), client); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// The signature must cover the exact content.
+	tampered := strings.Replace(captured, "Jelle van den Hooff", "Mallory", 1)
+	if err := CheckPlainEmail(tampered, capturedStatement, DefaultPolicy(), client); err == nil {
+		t.Errorf("expected tampered email to be rejected")
+	}
+
+	other := *capturedStatement
+	other.Token = "aaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if err := CheckPlainEmail(captured, &other, DefaultPolicy(), client); err == nil {
+		t.Errorf("expected email without token to be rejected")
+	}
+
+	other = *capturedStatement
+	other.Sender = "bob@vandenhooff.name"
+	if err := CheckPlainEmail(captured, &other, DefaultPolicy(), client); err == nil {
+		t.Errorf("expected email from other sender to be rejected")
+	}
+}
+
+func TestCheckProofUntrustedAttester(t *testing.T) {
+	client := loadFixture(t)
+
+	// An archived key attested by a server we don't trust is ignored in
+	// favor of the key in DNS.
+	encoded, err := EncodeProof(&wire.DKIMProof{
+		Headers: readMail(t, "captured.eml"),
+		Key: &wire.SignedDKIMKey{
+			Key: &wire.DKIMKey{
+				Hostname: "google._domainkey.vandenhooff.name.",
+				Records:  []string{"v=DKIM1; p=abc"},
+			},
+			PublicKey: "untrusted",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckProof(encoded, capturedStatement, DefaultPolicy(), client, map[string]bool{}, true); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestParseSignatureTags(t *testing.T) {
+	tags := parseTags([]string{"v=1; a=rsa-sha256; d=example.com; s=sel;\r\n\th=From:To:\r\n\t Subject; l=100; bh=abc=; b=def"})
+	if tags["a"] != "rsa-sha256" || tags["d"] != "example.com" || tags["s"] != "sel" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if tags["h"] != "From:To:Subject" || tags["l"] != "100" || tags["bh"] != "abc=" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	client := loadFixture(t)
+
+	captured, key, err := VerifyAndRecordKey(readMail(t, "captured.eml"), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Hostname != "google._domainkey.vandenhooff.name." || len(key.Records) != 1 {
+		t.Errorf("recorded unexpected key %v", key)
+	}
+	ed25519, ed25519Key, err := VerifyAndRecordKey(readMail(t, "ed25519.eml"), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DefaultPolicy().Check(captured, key); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := DefaultPolicy().Check(ed25519, ed25519Key); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// The captured email was signed with a 1024-bit key.
+	policy := DefaultPolicy()
+	policy.MinRSABits = 2048
+	if err := policy.Check(captured, key); err == nil {
+		t.Errorf("expected email signed with short key to be rejected")
+	}
+
+	policy = DefaultPolicy()
+	policy.AllowedAlgorithms = []string{"rsa-sha256"}
+	if err := policy.Check(ed25519, ed25519Key); err == nil {
+		t.Errorf("expected disallowed algorithm to be rejected")
+	}
+
+	policy = DefaultPolicy()
+	policy.RequiredHeaders = append(policy.RequiredHeaders, "cc")
+	if err := policy.Check(captured, key); err == nil {
+		t.Errorf("expected signature without required header to be rejected")
+	}
+}
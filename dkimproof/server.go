@@ -1,38 +1,52 @@
 package dkimproof
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/jellevandenhooff/dkim"
-	"github.com/jellevandenhooff/smtp"
+	"golang.org/x/net/netutil"
 
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/smimeproof"
+	"github.com/jellevandenhooff/keytree/smtp"
 	"github.com/jellevandenhooff/keytree/unixtime"
 	"github.com/jellevandenhooff/keytree/wire"
 )
 
 type Server struct {
-	mu        sync.Mutex
-	domain    string
-	pending   map[string]*wire.DKIMUpdate
-	dnsClient dkim.DNSClient
+	mu     sync.Mutex // serializes updates to pending
+	config *Config
+	policy *Policy
+
+	smimeTrust *x509.CertPool // may be nil
+	pending    PendingStore
+	changes    *notifier
+	dnsClient  dkim.DNSClient
+	mailStore  *MailStore // may be nil
+
+	// attests the DNS keys archived in proofs
+	publicKey string
+	signer    *crypto.Signer
 }
 
 func (s *Server) cleanOldPending() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for k, v := range s.pending {
-		if v.Expiration < unixtime.Now() {
-			delete(s.pending, k)
-		}
+	if err := s.pending.ExpirePending(unixtime.Now()); err != nil {
+		log.Printf("could not expire pending dkim verifications: %s\n", err)
 	}
+	s.changes.notifyAll()
 }
 
 func (s *Server) run() {
@@ -42,36 +56,95 @@ func (s *Server) run() {
 	}
 }
 
+// dkimProof checks an email verified with err by key against statement, and
+// returns an encoded proof.
+func (s *Server) dkimProof(verified *VerifiedEmail, key *wire.DKIMKey, err error, statement *wire.DKIMStatement) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if err := s.policy.Check(verified, key); err != nil {
+		return "", err
+	}
+	if err := CheckVerifiedEmail(verified, statement); err != nil {
+		return "", err
+	}
+
+	return EncodeProof(&wire.DKIMProof{
+		Headers: verified.CanonHeaders,
+		Key: &wire.SignedDKIMKey{
+			Key:       key,
+			PublicKey: s.publicKey,
+			Signature: s.signer.Sign(key),
+		},
+	})
+}
+
+func (s *Server) smimeProof(mail string, statement *wire.DKIMStatement) (string, error) {
+	proof, err := smimeproof.ExtractProof(mail)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := smimeproof.EncodeProof(proof)
+	if err != nil {
+		return "", err
+	}
+	// The entry was made shortly before the mail was sent.
+	if err := smimeproof.CheckProof(encoded, statement, s.smimeTrust, unixtime.Now()); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
 func (s *Server) handleMail(m *smtp.Mail) {
-	/*
-		mail_id := base32.EncodeToString(crypto.HashString(m.Mail).Bytes()[:8])
-		if err := ioutil.WriteFile(filepath.Join("received-emails", mail_id), []byte(m.Mail), 0644); err != nil {
-			log.Printf("could not store email: %s\n", err)
+	mailID := MailID(m.Mail)
+	if s.mailStore != nil {
+		if _, err := s.mailStore.Store(m.Mail); err != nil {
+			log.Printf("could not store email %s: %s\n", mailID, err)
 		}
-	*/
+	}
 
 	// Hold on to err for later reporting
-	verified, err := dkim.ParseAndVerify(m.Mail, dkim.HeadersOnly, s.dnsClient)
+	verified, key, err := VerifyAndRecordKey(m.Mail, s.dnsClient)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	update := s.pending[strings.ToLower(m.To)]
+	to := s.config.pendingAddress(m.To)
+	if to == "" {
+		return
+	}
+	update, readErr := s.pending.ReadPending(to)
+	if readErr != nil {
+		log.Printf("could not read pending dkim verification for %s: %s\n", to, readErr)
+		return
+	}
 	if update == nil {
 		return
 	}
 
-	if err == nil {
-		err = CheckVerifiedEmail(verified, update.Statement)
+	proofType := "dkim"
+	proof, err := s.dkimProof(verified, key, err, update.Statement)
+	if err != nil {
+		// Fall back to an S/MIME signature, if there is one.
+		smimeProof, smimeErr := s.smimeProof(m.Mail, update.Statement)
+		if smimeErr == nil {
+			proofType, proof, err = "smime", smimeProof, nil
+		} else if smimeErr != smimeproof.ErrNotSigned {
+			err = fmt.Errorf("%s; s/mime: %s", err, smimeErr)
+		}
 	}
 
 	if err != nil {
-		update.Status = append(update.Status, fmt.Sprintf("%s", err.Error()))
-		// update.Status = append(update.Status, fmt.Sprintf("%s (%s)", err.Error(), mail_id))
-		return
+		update.Status = append(update.Status, fmt.Sprintf("%s (mail %s)", err.Error(), mailID))
+	} else {
+		update.ProofType = proofType
+		update.Proof = proof
 	}
 
-	update.Proof = verified.CanonHeaders()
+	if err := s.pending.WritePending(to, update); err != nil {
+		log.Printf("could not write pending dkim verification for %s: %s\n", to, err)
+	}
+	s.changes.notify(to)
 }
 
 func (s *Server) handlePrepare(w http.ResponseWriter, r *http.Request) {
@@ -91,55 +164,138 @@ func (s *Server) handlePrepare(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	email := crypto.GenerateRandomToken(6) + "@" + s.domain
-	s.pending[email] = &wire.DKIMUpdate{
+	email := crypto.GenerateRandomToken(6) + "@" + strings.ToLower(s.config.Domain)
+	if err := s.pending.WritePending(email, &wire.DKIMUpdate{
 		Statement:  &req,
 		Proof:      "",
 		Status:     nil,
 		Expiration: unixtime.Now() + 15*60,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	wire.ReplyJSON(w, email)
 }
 
+// How long /dkim/poll waits for a change before replying anyway.
+const pollTimeout = 10 * time.Second
+
+// handlePoll replies with the status of a pending verification. If since is
+// set, it first waits until there are more than since status messages, a
+// proof, or pollTimeout passes, unless the client disconnects first.
 func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	email := r.URL.Query().Get("email")
 
-	update, found := s.pending[r.URL.Query().Get("email")]
-	if !found {
-		http.NotFound(w, r)
+	since := -1
+	if sinceString := r.URL.Query().Get("since"); sinceString != "" {
+		var err error
+		if since, err = strconv.Atoi(sinceString); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	wire.ReplyJSON(w, &wire.DKIMStatus{
-		Proof:      update.Proof,
-		Status:     update.Status,
-		Expiration: update.Expiration,
-	})
+	timeout := time.After(pollTimeout)
+	for {
+		changed := s.changes.wait(email)
+
+		update, err := s.pending.ReadPending(email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if update == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		done := since < 0 || len(update.Status) > since || update.Proof != ""
+		if !done {
+			select {
+			case <-changed:
+				continue
+			case <-timeout:
+			case <-r.Context().Done():
+				// The client went away; nobody is left to reply to.
+				return
+			}
+		}
+
+		wire.ReplyJSON(w, &wire.DKIMStatus{
+			ProofType:  update.ProofType,
+			Proof:      update.Proof,
+			Status:     update.Status,
+			Expiration: update.Expiration,
+		})
+		return
+	}
 }
 
-func RunServer(domain string, dnsClient dkim.DNSClient) (*Server, error) {
+// RunServer starts receiving mail as described by config, accepting only
+// signatures allowed by policy. Pending verifications are kept in pending, so
+// mail for verifications started before a restart still matches.
+func RunServer(config *Config, policy *Policy, dnsClient dkim.DNSClient, pending PendingStore, mailStore *MailStore, publicKey string, signer *crypto.Signer) (*Server, error) {
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+
 	s := &Server{
-		domain:    domain,
-		pending:   make(map[string]*wire.DKIMUpdate),
+		config:    config,
+		policy:    policy,
+		pending:   pending,
+		changes:   newNotifier(),
 		dnsClient: dnsClient,
+		mailStore: mailStore,
+		publicKey: publicKey,
+		signer:    signer,
 	}
 
-	l, err := net.Listen("tcp", ":smtp")
+	var tlsConfig *tls.Config
+	if config.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	if config.SMIMETrustStoreFile != "" {
+		smimeTrust, err := smimeproof.LoadTrustStore(config.SMIMETrustStoreFile)
+		if err != nil {
+			return nil, err
+		}
+		s.smimeTrust = smimeproof.NewCertPool(smimeTrust)
+	}
+
+	l, err := net.Listen("tcp", config.ListenAddr)
 	if err != nil {
 		return nil, err
 	}
 
-	go smtp.Serve(domain, l, func(m *smtp.Mail) {
-		s.handleMail(m)
-	})
+	smtpServer := &smtp.Server{
+		Domain: config.Domain,
+		Handler: func(m *smtp.Mail) {
+			s.handleMail(m)
+		},
+		TLSConfig:      tlsConfig,
+		MaxMessageSize: config.MaxMessageSize,
+		CommandTimeout: time.Duration(config.CommandTimeoutSeconds) * time.Second,
+		IdleTimeout:    time.Duration(config.IdleTimeoutSeconds) * time.Second,
+	}
+	go smtpServer.Serve(netutil.LimitListener(l, config.MaxConnections))
 
 	go s.run()
 	return s, nil
 }
 
+// Domain returns the domain at which verification addresses are handed out.
+func (s *Server) Domain() string {
+	return s.config.Domain
+}
+
 func (s *Server) AddHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/dkim/prepare", func(w http.ResponseWriter, r *http.Request) {
 		s.handlePrepare(w, r)
@@ -0,0 +1,41 @@
+package dkimproof
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func TestPollStopsWhenClientLeaves(t *testing.T) {
+	s := &Server{
+		pending: NewMemoryPendingStore(),
+		changes: newNotifier(),
+	}
+	if err := s.pending.WritePending("abc@keytree.io", &wire.DKIMUpdate{Expiration: 1 << 40}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/dkim/poll?email=abc@keytree.io&since=0", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlePoll(w, r)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(pollTimeout / 2):
+		t.Fatal("expected poll to stop once the client left")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no reply; got %q", w.Body.String())
+	}
+}
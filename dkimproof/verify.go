@@ -0,0 +1,223 @@
+package dkimproof
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/agl/ed25519"
+	"github.com/jellevandenhooff/dkim"
+)
+
+// A VerifiedEmail stores the verified parts of an email.
+type VerifiedEmail struct {
+	// Signing domain
+	Domain string
+
+	// Signed headers (in original form)
+	Headers []string
+
+	// Tags of the DKIM-Signature header that verified
+	Tags map[string]string
+
+	// Canonical headers-only form of the email; itself a valid proof
+	CanonHeaders string
+}
+
+// ExtractHeader retrieves the named header from the signed headers.
+func (e *VerifiedEmail) ExtractHeader(name string) []string {
+	return extractHeaders(e.Headers, []string{name})
+}
+
+// parseHeaders splits the header section of mail into header fields, each
+// including its folded lines and trailing CRLF.
+func parseHeaders(mail string) []string {
+	var headers []string
+	for len(mail) > 0 {
+		end := len(mail)
+		for i := 0; i < len(mail); i++ {
+			if mail[i] == '\n' && (i+1 == len(mail) || (mail[i+1] != ' ' && mail[i+1] != '\t')) {
+				end = i + 1
+				break
+			}
+		}
+		header := mail[:end]
+		mail = mail[end:]
+		if header == "\r\n" {
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// extractHeaders picks the headers listed in names, taking repeated headers
+// from the bottom up as described in RFC 6376 section 5.4.2.
+func extractHeaders(headers []string, names []string) []string {
+	byName := make(map[string][]string)
+	for _, header := range headers {
+		nameEnd := strings.Index(header, ":")
+		if nameEnd == -1 {
+			nameEnd = len(header)
+		}
+		name := strings.ToLower(strings.TrimSpace(header[:nameEnd]))
+		byName[name] = append(byName[name], header)
+	}
+
+	var extracted []string
+	for _, name := range names {
+		name = strings.ToLower(name)
+		headers := byName[name]
+		if len(headers) > 0 {
+			extracted = append(extracted, headers[len(headers)-1])
+			byName[name] = headers[:len(headers)-1]
+		}
+	}
+	return extracted
+}
+
+const signaturePrefix = "dkim-signature:"
+
+func isSignatureHeader(header string) bool {
+	return strings.HasPrefix(strings.ToLower(header), signaturePrefix)
+}
+
+func signatureTags(header string) map[string]string {
+	return parseTags([]string{header[len(signaturePrefix):]})
+}
+
+// trimSignature removes the value of the b= tag from a DKIM-Signature header,
+// leaving the header that was signed.
+func trimSignature(header string) string {
+	pairs := strings.Split(header[len(signaturePrefix):], ";")
+	for i, pair := range pairs {
+		idx := strings.IndexByte(pair, '=')
+		if idx != -1 && strings.TrimSpace(pair[:idx]) == "b" {
+			pairs[i] = pair[:idx+1]
+		}
+	}
+	return header[:len(signaturePrefix)] + strings.Join(pairs, ";")
+}
+
+func simpleHeader(header string) string {
+	return header
+}
+
+// relaxHeader canonicalizes a header as described in RFC 6376 section 3.4.2.
+func relaxHeader(header string) string {
+	idx := strings.IndexByte(header, ':')
+	if idx == -1 {
+		return header
+	}
+	name := strings.ToLower(strings.TrimRight(header[:idx], " \t"))
+	value := strings.Join(strings.Fields(header[idx+1:]), " ")
+	return name + ":" + value + "\r\n"
+}
+
+// verifyEd25519 verifies the headers of mail signed with ed25519-sha256, as
+// described in RFC 8463, which the dkim package does not support.
+func verifyEd25519(mail string, dnsClient dkim.DNSClient) (*VerifiedEmail, error) {
+	headers := parseHeaders(mail)
+	var signatureHeader string
+	for _, header := range headers {
+		if isSignatureHeader(header) {
+			signatureHeader = header
+		}
+	}
+	tags := signatureTags(signatureHeader)
+
+	var canon func(string) string
+	switch strings.SplitN(tags["c"], "/", 2)[0] {
+	case "", "simple":
+		canon = simpleHeader
+	case "relaxed":
+		canon = relaxHeader
+	default:
+		return nil, errors.New("unknown canon")
+	}
+	if tags["d"] == "" || tags["s"] == "" {
+		return nil, errors.New("missing domain or selector")
+	}
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, errors.New("bad signature")
+	}
+
+	txtRecords, err := dnsClient.LookupTxt(tags["s"] + "._domainkey." + tags["d"] + ".")
+	if err != nil {
+		return nil, err
+	}
+
+	signedHeaders := extractHeaders(headers, strings.Split(tags["h"], ":"))
+
+	var canonHeaders []string
+	h := sha256.New()
+	for _, header := range signedHeaders {
+		header = canon(header)
+		canonHeaders = append(canonHeaders, header)
+		h.Write([]byte(header))
+	}
+	// The signature header is hashed without its trailing CRLF.
+	h.Write([]byte(strings.TrimSuffix(canon(trimSignature(signatureHeader)), "\r\n")))
+	headersHash := h.Sum(nil)
+
+	var sig [ed25519.SignatureSize]byte
+	copy(sig[:], signature)
+
+	found := false
+	for _, txtRecord := range txtRecords {
+		key, err := base64.StdEncoding.DecodeString(parseTags([]string{txtRecord})["p"])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			continue
+		}
+		var pub [ed25519.PublicKeySize]byte
+		copy(pub[:], key)
+		if ed25519.Verify(&pub, headersHash, &sig) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("no valid DKIM signature")
+	}
+
+	return &VerifiedEmail{
+		Domain:       tags["d"],
+		Headers:      signedHeaders,
+		Tags:         tags,
+		CanonHeaders: strings.Join(append(canonHeaders, canon(signatureHeader)), ""),
+	}, nil
+}
+
+// verifyEmail verifies the DKIM signature on the headers of mail.
+func verifyEmail(mail string, dnsClient dkim.DNSClient) (*VerifiedEmail, error) {
+	var signatureHeaders []string
+	for _, header := range parseHeaders(mail) {
+		if isSignatureHeader(header) {
+			signatureHeaders = append(signatureHeaders, header)
+		}
+	}
+	if len(signatureHeaders) == 1 && strings.EqualFold(signatureTags(signatureHeaders[0])["a"], "ed25519-sha256") {
+		return verifyEd25519(mail, dnsClient)
+	}
+
+	email, err := dkim.ParseAndVerify(mail, dkim.HeadersOnly, dnsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The dkim package does not expose the tags of the signature that
+	// verified; its canonical form ends with that signature's header.
+	canonHeaders := email.CanonHeaders()
+	headers := parseHeaders(canonHeaders)
+	if len(headers) == 0 || !isSignatureHeader(headers[len(headers)-1]) {
+		return nil, errors.New("could not find verified dkim signature header")
+	}
+
+	return &VerifiedEmail{
+		Domain:       email.Signature.Domain,
+		Headers:      email.Headers,
+		Tags:         signatureTags(headers[len(headers)-1]),
+		CanonHeaders: canonHeaders,
+	}, nil
+}
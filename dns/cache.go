@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+)
+
+const cacheSize = 8192
+
+// Bounds on how long answers are cached, whatever their TTL.
+const DefaultMinTTL = 1 * time.Minute
+const DefaultMaxTTL = 1 * time.Hour
+
+// CachingDNSClient caches answers from an underlying resolver for their TTL,
+// including NXDOMAIN answers, and merges concurrent lookups of the same
+// hostname. Failed lookups are not cached. A CachingDNSClient is threadsafe.
+type CachingDNSClient struct {
+	underlying     TxtResolver
+	cache          *lru.Cache
+	minTTL, maxTTL time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*lookupCall
+
+	stats CacheStats
+}
+
+// CacheStats counts lookups by how they were answered.
+type CacheStats struct {
+	Hits         uint64 // answered from the cache
+	NegativeHits uint64 // answered with a cached NXDOMAIN
+	Misses       uint64 // sent to the underlying resolver
+	Coalesced    uint64 // waited for an identical lookup in progress
+	Errors       uint64 // failed in the underlying resolver
+}
+
+type cacheItem struct {
+	result  *TxtResult
+	expires time.Time
+}
+
+// A lookupCall is a lookup in progress that other lookups can wait for.
+type lookupCall struct {
+	done   chan struct{}
+	result *TxtResult
+	err    error
+}
+
+func NewCachingDNSClient(server string) *CachingDNSClient {
+	return NewCachingResolver(&SimpleDNSClient{Server: server}, DefaultMinTTL, DefaultMaxTTL)
+}
+
+// NewCachingResolver caches answers from underlying for their TTL, clamped to
+// between minTTL and maxTTL.
+func NewCachingResolver(underlying TxtResolver, minTTL, maxTTL time.Duration) *CachingDNSClient {
+	cache, _ := lru.New(cacheSize)
+
+	return &CachingDNSClient{
+		underlying: underlying,
+		cache:      cache,
+		minTTL:     minTTL,
+		maxTTL:     maxTTL,
+		inflight:   make(map[string]*lookupCall),
+	}
+}
+
+func (c *CachingDNSClient) clampTTL(ttl time.Duration) time.Duration {
+	if ttl < c.minTTL {
+		return c.minTTL
+	}
+	if ttl > c.maxTTL {
+		return c.maxTTL
+	}
+	return ttl
+}
+
+func (c *CachingDNSClient) cached(hostname string) *TxtResult {
+	value, ok := c.cache.Get(hostname)
+	if !ok {
+		return nil
+	}
+	item := value.(*cacheItem)
+	if !time.Now().Before(item.expires) {
+		return nil
+	}
+
+	if item.result.NXDomain {
+		atomic.AddUint64(&c.stats.NegativeHits, 1)
+	} else {
+		atomic.AddUint64(&c.stats.Hits, 1)
+	}
+	return item.result
+}
+
+func (c *CachingDNSClient) ResolveTxt(hostname string) (*TxtResult, error) {
+	if result := c.cached(hostname); result != nil {
+		return result, nil
+	}
+
+	c.mu.Lock()
+	if call, found := c.inflight[hostname]; found {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.stats.Coalesced, 1)
+		<-call.done
+		return call.result, call.err
+	}
+	call := &lookupCall{done: make(chan struct{})}
+	c.inflight[hostname] = call
+	c.mu.Unlock()
+
+	atomic.AddUint64(&c.stats.Misses, 1)
+	call.result, call.err = c.underlying.ResolveTxt(hostname)
+	if call.err != nil {
+		atomic.AddUint64(&c.stats.Errors, 1)
+	} else {
+		c.cache.Add(hostname, &cacheItem{
+			result:  call.result,
+			expires: time.Now().Add(c.clampTTL(call.result.TTL)),
+		})
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, hostname)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.result, call.err
+}
+
+func (c *CachingDNSClient) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(c, hostname)
+}
+
+func (c *CachingDNSClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:         atomic.LoadUint64(&c.stats.Hits),
+		NegativeHits: atomic.LoadUint64(&c.stats.NegativeHits),
+		Misses:       atomic.LoadUint64(&c.stats.Misses),
+		Coalesced:    atomic.LoadUint64(&c.stats.Coalesced),
+		Errors:       atomic.LoadUint64(&c.stats.Errors),
+	}
+}
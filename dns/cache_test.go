@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	mu      sync.Mutex
+	results map[string]*TxtResult
+	queries int
+	block   chan struct{} // if set, lookups wait for it to close
+}
+
+func (f *fakeResolver) ResolveTxt(hostname string) (*TxtResult, error) {
+	if f.block != nil {
+		<-f.block
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries++
+
+	result, found := f.results[hostname]
+	if !found {
+		return nil, errors.New("servfail")
+	}
+	return result, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	resolver := &fakeResolver{results: map[string]*TxtResult{
+		"short.example.": {Records: []string{"a"}, TTL: time.Second},
+		"gone.example.":  {NXDomain: true, TTL: time.Hour},
+	}}
+	c := NewCachingResolver(resolver, time.Minute, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if records, err := c.LookupTxt("short.example."); err != nil || len(records) != 1 {
+			t.Errorf("lookup returned %v, %v", records, err)
+		}
+		if _, err := c.LookupTxt("gone.example."); err != ErrNXDomain {
+			t.Errorf("lookup of missing host returned %v", err)
+		}
+		if _, err := c.LookupTxt("broken.example."); err == nil {
+			t.Errorf("failed lookup succeeded")
+		}
+	}
+
+	// The short TTL is raised to the minimum, so only the failed lookup is
+	// repeated.
+	if resolver.queries != 4 {
+		t.Errorf("expected 4 queries, got %d", resolver.queries)
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.NegativeHits != 1 || stats.Misses != 4 || stats.Errors != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCachingResolverCoalesces(t *testing.T) {
+	resolver := &fakeResolver{
+		results: map[string]*TxtResult{"a.example.": {Records: []string{"a"}, TTL: time.Hour}},
+		block:   make(chan struct{}),
+	}
+	c := NewCachingResolver(resolver, time.Minute, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.LookupTxt("a.example."); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Wait until all lookups are either waiting on the first or in flight.
+	for {
+		stats := c.Stats()
+		if stats.Misses+stats.Coalesced == 10 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(resolver.block)
+	wg.Wait()
+
+	if resolver.queries != 1 {
+		t.Errorf("expected 1 query, got %d", resolver.queries)
+	}
+}
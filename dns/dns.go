@@ -1,37 +1,71 @@
 package dns
 
 import (
+	"crypto/tls"
 	"errors"
 	"strings"
 	"time"
 
-	"github.com/hashicorp/golang-lru"
 	"github.com/miekg/dns"
 )
 
+// ErrNXDomain is returned for lookups of hostnames that do not exist.
+var ErrNXDomain = errors.New("no such host")
+
+// A TxtResult is the answer to a TXT lookup, with how long it may be cached.
+type TxtResult struct {
+	Records  []string
+	TTL      time.Duration
+	NXDomain bool
+
+	// Set by a Validator: whether the records come from a DNSSEC-signed
+	// zone, and whether their signatures validated up to a trust anchor.
+	// Signed records that did not validate are bogus.
+	Signed    bool `json:",omitempty"`
+	Validated bool `json:",omitempty"`
+}
+
+// An Exchanger sends a DNS query and returns the reply.
+type Exchanger interface {
+	Exchange(m *dns.Msg) (*dns.Msg, error)
+}
+
+// A TxtResolver looks up TXT records, reporting TTLs and NXDOMAIN answers
+// instead of only the records.
+type TxtResolver interface {
+	ResolveTxt(hostname string) (*TxtResult, error)
+}
+
 // SimpleDNSClient uses miekg/dns to look up TXT records, and supports falling
 // back to TCP for big records.
 //
 // Exists because Go's built-in DNS client has problems with some TXT records.
 type SimpleDNSClient struct {
 	Server string
+
+	// How long to wait for each exchange; if 0, miekg/dns's default.
+	Timeout time.Duration
+	// If set, queries are sent over DNS-over-TLS instead of UDP and TCP.
+	TLSConfig *tls.Config
 }
 
-// LookupTxt queries the underlying server for TXT records for the given
-// hostname.
-func (s *SimpleDNSClient) LookupTxt(hostname string) ([]string, error) {
-	// build the DNS query
-	m := new(dns.Msg)
-	m.SetQuestion(hostname, dns.TypeTXT)
+// Exchange sends m to the underlying server and returns the reply.
+func (s *SimpleDNSClient) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	c := &dns.Client{
+		Timeout: s.Timeout,
+	}
+	if s.TLSConfig != nil {
+		c.Net = "tcp-tls"
+		c.TLSConfig = s.TLSConfig
+	}
 
 	// try getting over UDP
-	c := new(dns.Client)
 	r, _, e := c.Exchange(m, s.Server)
 	if e != nil {
 		return nil, e
 	}
 
-	if r.Truncated {
+	if r.Truncated && s.TLSConfig == nil {
 		// try again with TCP for large messages
 		c.Net = "tcp"
 		r, _, e = c.Exchange(m, s.Server)
@@ -40,58 +74,129 @@ func (s *SimpleDNSClient) LookupTxt(hostname string) ([]string, error) {
 		}
 	}
 
-	// parse TXT answers into strings
-	var res []string
-	for _, answer := range r.Answer {
-		txt, ok := answer.(*dns.TXT)
-		if !ok {
-			return nil, errors.New("expected TXT")
+	return r, nil
+}
+
+// ResolveTxt queries the underlying server for TXT records for the given
+// hostname.
+func (s *SimpleDNSClient) ResolveTxt(hostname string) (*TxtResult, error) {
+	return resolveTxt(s, hostname)
+}
+
+func resolveTxt(e Exchanger, hostname string) (*TxtResult, error) {
+	// build the DNS query
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(hostname), dns.TypeTXT)
+
+	r, err := e.Exchange(m)
+	if err != nil {
+		return nil, err
+	}
+	return parseTxtReply(r, hostname)
+}
+
+// Limits how many CNAMEs are followed within a reply.
+const maxCNAMEs = 8
+
+// answerChain follows the CNAMEs in r's answer section starting at hostname.
+// It returns the name that holds the answer, and the aliases leading to it.
+func answerChain(r *dns.Msg, hostname string) (string, []string) {
+	name := dns.CanonicalName(hostname)
+	var aliases []string
+	for i := 0; i < maxCNAMEs; i++ {
+		next := ""
+		for _, answer := range r.Answer {
+			if cname, ok := answer.(*dns.CNAME); ok && dns.CanonicalName(cname.Hdr.Name) == name {
+				next = dns.CanonicalName(cname.Target)
+			}
 		}
-		// concatenate each multi-part answer into a single string
-		res = append(res, strings.Join(txt.Txt, ""))
+		if next == "" {
+			break
+		}
+		aliases = append(aliases, name)
+		name = next
 	}
-	return res, nil
+	return name, aliases
 }
 
-type CachingDNSClient struct {
-	underlying *SimpleDNSClient
-	cache      *lru.Cache
+// ownedBy returns the records in rrs owned by name.
+func ownedBy(rrs []dns.RR, name string) []dns.RR {
+	var owned []dns.RR
+	for _, rr := range rrs {
+		if dns.CanonicalName(rr.Header().Name) == name {
+			owned = append(owned, rr)
+		}
+	}
+	return owned
 }
 
-const cacheSize = 8192
-const lifetime = 1 * time.Hour
+// parseTxtReply extracts TXT records for hostname from a reply, following
+// CNAMEs; records for other names are ignored. The TTL is the lowest of the
+// records used, or for NXDOMAIN, the negative TTL from the SOA record.
+func parseTxtReply(r *dns.Msg, hostname string) (*TxtResult, error) {
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return &TxtResult{NXDomain: true, TTL: negativeTTL(r)}, nil
+	default:
+		return nil, errors.New("dns query failed: " + dns.RcodeToString[r.Rcode])
+	}
 
-type cacheItem struct {
-	result  []string
-	expires time.Time
-}
+	// parse TXT answers into strings
+	target, aliases := answerChain(r, hostname)
+	var answers []dns.RR
+	for _, alias := range aliases {
+		answers = append(answers, ownedBy(r.Answer, alias)...)
+	}
+	answers = append(answers, ownedBy(r.Answer, target)...)
 
-func NewCachingDNSClient(server string) *CachingDNSClient {
-	cache, _ := lru.New(cacheSize)
+	result := &TxtResult{TTL: negativeTTL(r)}
+	first := true
+	for _, answer := range answers {
+		ttl := time.Duration(answer.Header().Ttl) * time.Second
+		if first || ttl < result.TTL {
+			result.TTL = ttl
+			first = false
+		}
 
-	return &CachingDNSClient{
-		underlying: &SimpleDNSClient{Server: server},
-		cache:      cache,
+		// skip CNAMEs leading to the TXT records
+		txt, ok := answer.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		// concatenate each multi-part answer into a single string
+		result.Records = append(result.Records, strings.Join(txt.Txt, ""))
 	}
+	return result, nil
 }
 
-func (c *CachingDNSClient) LookupTxt(hostname string) ([]string, error) {
-	value, ok := c.cache.Get(hostname)
-	if ok {
-		item := value.(*cacheItem)
-		if time.Now().Before(item.expires) {
-			return item.result, nil
+func negativeTTL(r *dns.Msg) time.Duration {
+	for _, rr := range r.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl := soa.Hdr.Ttl
+			if soa.Minttl < ttl {
+				ttl = soa.Minttl
+			}
+			return time.Duration(ttl) * time.Second
 		}
 	}
+	return 0
+}
 
-	result, err := c.underlying.LookupTxt(hostname)
+// lookupTxt adapts a TxtResolver to dkim.DNSClient.
+func lookupTxt(resolver TxtResolver, hostname string) ([]string, error) {
+	result, err := resolver.ResolveTxt(hostname)
 	if err != nil {
 		return nil, err
 	}
+	if result.NXDomain {
+		return nil, ErrNXDomain
+	}
+	return result.Records, nil
+}
 
-	c.cache.Add(hostname, &cacheItem{
-		result:  result,
-		expires: time.Now().Add(lifetime),
-	})
-	return result, nil
+// LookupTxt queries the underlying server for TXT records for the given
+// hostname.
+func (s *SimpleDNSClient) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(s, hostname)
 }
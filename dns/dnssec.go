@@ -0,0 +1,276 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The DS record of the root zone's 2017 key signing key.
+const RootTrustAnchor = ". 86400 IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+
+// Limits how many zones are walked up to find a trust anchor.
+const maxChainLength = 16
+
+type DNSSECConfig struct {
+	// DS records, in zone file format, for zones whose keys are trusted.
+	// If empty, RootTrustAnchor.
+	TrustAnchors []string `json:",omitempty"`
+}
+
+// A Validator looks up TXT records with DNSSEC signatures and validates them
+// up to a trust anchor. It reports the outcome in TxtResult instead of failing
+// lookups, so that policy can decide what to accept. Negative answers are
+// never validated. A Validator is threadsafe if its Exchanger is.
+type Validator struct {
+	exchanger Exchanger
+	anchors   map[string][]*dns.DS // by zone
+	now       func() time.Time
+}
+
+func NewValidator(exchanger Exchanger, config *DNSSECConfig) (*Validator, error) {
+	anchors := config.TrustAnchors
+	if len(anchors) == 0 {
+		anchors = []string{RootTrustAnchor}
+	}
+
+	v := &Validator{
+		exchanger: exchanger,
+		anchors:   make(map[string][]*dns.DS),
+		now:       time.Now,
+	}
+	for _, anchor := range anchors {
+		rr, err := dns.NewRR(anchor)
+		if err != nil {
+			return nil, fmt.Errorf("bad trust anchor: %s", err)
+		}
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			return nil, fmt.Errorf("trust anchor '%s' is not a DS record", anchor)
+		}
+		zone := dns.CanonicalName(ds.Hdr.Name)
+		v.anchors[zone] = append(v.anchors[zone], ds)
+	}
+	return v, nil
+}
+
+func (v *Validator) query(name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(4096, true)
+	return v.exchanger.Exchange(m)
+}
+
+// split separates the records of type qtype in rrs from the signatures over
+// them.
+func split(rrs []dns.RR, qtype uint16) ([]dns.RR, []*dns.RRSIG) {
+	var records []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			if sig.TypeCovered == qtype {
+				sigs = append(sigs, sig)
+			}
+		} else if rr.Header().Rrtype == qtype {
+			records = append(records, rr)
+		}
+	}
+	return records, sigs
+}
+
+func (v *Validator) ResolveTxt(hostname string) (*TxtResult, error) {
+	r, err := v.query(hostname, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseTxtReply(r, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Records) == 0 {
+		return result, nil
+	}
+
+	// Every step from hostname to the records must check out on its own: a
+	// signature over records for another name says nothing about hostname.
+	target, aliases := answerChain(r, hostname)
+	bogus := false
+	for _, name := range append(aliases, target) {
+		qtype := dns.TypeCNAME
+		if name == target {
+			qtype = dns.TypeTXT
+		}
+		signed, validated, err := v.checkRRset(ownedBy(r.Answer, name), qtype, name)
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			result.Signed = true
+			bogus = bogus || !validated
+		}
+	}
+	result.Validated = result.Signed && !bogus
+	return result, nil
+}
+
+// checkRRset reports whether the qtype records in rrs, owned by name, come from
+// a signed zone, and if so, whether they validated. Whether the records should
+// be signed follows from the chain, not from the answer: an attacker can strip
+// signatures, but not forge DS records. Records from a signed zone without
+// signatures are bogus.
+func (v *Validator) checkRRset(rrs []dns.RR, qtype uint16, name string) (signed bool, validated bool, err error) {
+	records, sigs := split(rrs, qtype)
+	if len(sigs) == 0 {
+		signed, err := v.inSignedZone(name)
+		return signed, false, err
+	}
+	return true, v.verify(records, sigs, 0) == nil, nil
+}
+
+// inSignedZone reports whether the zone containing name is signed: whether the
+// nearest zone cut at or above name is a trust anchor or has a validated DS
+// record. Without checking denial-of-existence proofs, an unsigned delegation
+// below a signed zone is taken at its word.
+func (v *Validator) inSignedZone(name string) (bool, error) {
+	name = dns.CanonicalName(name)
+	for i := 0; i < maxChainLength; i++ {
+		if _, found := v.anchors[name]; found {
+			return true, nil
+		}
+
+		r, err := v.query(name, dns.TypeDS)
+		if err != nil {
+			return false, err
+		}
+		if records, sigs := split(r.Answer, dns.TypeDS); len(records) > 0 {
+			return v.verify(records, sigs, 0) == nil, nil
+		}
+
+		// No DS records; a zone apex here means an unsigned zone.
+		r, err = v.query(name, dns.TypeSOA)
+		if err != nil {
+			return false, err
+		}
+		if records, _ := split(r.Answer, dns.TypeSOA); len(records) > 0 {
+			return false, nil
+		}
+
+		off, end := dns.NextLabel(name, 0)
+		if end {
+			return false, nil
+		}
+		name = name[off:]
+	}
+	return false, errors.New("dnssec chain too long")
+}
+
+func (v *Validator) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(v, hostname)
+}
+
+// verify checks that one of sigs is a valid signature over rrset by a key of
+// a zone that validates up to a trust anchor. The signing zone must contain
+// rrset's owner, or any zone could sign records for any other.
+func (v *Validator) verify(rrset []dns.RR, sigs []*dns.RRSIG, depth int) error {
+	owner := dns.CanonicalName(rrset[0].Header().Name)
+	err := errors.New("no valid signature")
+	for _, sig := range sigs {
+		signer := dns.CanonicalName(sig.SignerName)
+		if !dns.IsSubDomain(signer, owner) {
+			err = fmt.Errorf("%s is signed by %s, which does not contain it", owner, signer)
+			continue
+		}
+		// DS records live in, and are signed by, the parent zone.
+		if rrset[0].Header().Rrtype == dns.TypeDS && signer == owner {
+			err = fmt.Errorf("ds records of %s are signed by the zone itself", owner)
+			continue
+		}
+		// Fewer labels than the owner means a wildcard expansion; more can't
+		// be right.
+		if int(sig.Labels) > dns.CountLabel(owner) {
+			err = fmt.Errorf("signature over %s has bad label count %d", owner, sig.Labels)
+			continue
+		}
+
+		keys, keysErr := v.zoneKeys(signer, depth)
+		if keysErr != nil {
+			err = keysErr
+			continue
+		}
+		if v.verifyWith(rrset, sig, keys) {
+			return nil
+		}
+	}
+	return err
+}
+
+func (v *Validator) verifyWith(rrset []dns.RR, sig *dns.RRSIG, keys []*dns.DNSKEY) bool {
+	if !sig.ValidityPeriod(v.now()) {
+		return false
+	}
+	for _, key := range keys {
+		if key.KeyTag() == sig.KeyTag && sig.Verify(key, rrset) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneKeys returns the keys of zone, after checking that they are signed by a
+// key matching one of the zone's DS records, which are in turn validated in
+// the parent zone, or are a trust anchor.
+func (v *Validator) zoneKeys(zone string, depth int) ([]*dns.DNSKEY, error) {
+	if depth >= maxChainLength {
+		return nil, errors.New("dnssec chain too long")
+	}
+
+	r, err := v.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	keyRecords, keySigs := split(r.Answer, dns.TypeDNSKEY)
+	var keys []*dns.DNSKEY
+	for _, rr := range keyRecords {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+
+	dsRecords, found := v.anchors[zone]
+	if !found {
+		r, err := v.query(zone, dns.TypeDS)
+		if err != nil {
+			return nil, err
+		}
+		records, sigs := split(r.Answer, dns.TypeDS)
+		if len(records) == 0 {
+			return nil, fmt.Errorf("no ds records for %s", zone)
+		}
+		if err := v.verify(records, sigs, depth+1); err != nil {
+			return nil, err
+		}
+		for _, rr := range records {
+			dsRecords = append(dsRecords, rr.(*dns.DS))
+		}
+	}
+
+	// The key set must be signed by a key the DS records vouch for.
+	var trusted []*dns.DNSKEY
+	for _, key := range keys {
+		for _, ds := range dsRecords {
+			if key.KeyTag() != ds.KeyTag {
+				continue
+			}
+			if digest := key.ToDS(ds.DigestType); digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+				trusted = append(trusted, key)
+			}
+		}
+	}
+	for _, sig := range keySigs {
+		if v.verifyWith(keyRecords, sig, trusted) {
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("no trusted signature over keys of %s", zone)
+}
@@ -0,0 +1,189 @@
+package dns
+
+import (
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// A fakeZones answers queries from a fixed set of records, following CNAMEs
+// like a recursive resolver.
+type fakeZones struct {
+	records []dns.RR
+}
+
+func (f *fakeZones) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	r := new(dns.Msg)
+	r.SetReply(m)
+	q := m.Question[0]
+	name := dns.CanonicalName(q.Name)
+	for i := 0; i < maxCNAMEs; i++ {
+		next := ""
+		for _, rr := range f.records {
+			if dns.CanonicalName(rr.Header().Name) != name {
+				continue
+			}
+			covered := rr.Header().Rrtype
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				covered = sig.TypeCovered
+			}
+			if covered == q.Qtype || covered == dns.TypeCNAME {
+				r.Answer = append(r.Answer, rr)
+			}
+			if cname, ok := rr.(*dns.CNAME); ok {
+				next = dns.CanonicalName(cname.Target)
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return r, nil
+}
+
+type testZone struct {
+	key     *dns.DNSKEY
+	private crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	private, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testZone{key: key, private: private.(crypto.Signer)}
+}
+
+// A spoofingZones answers TXT queries for one name with the records of
+// another, as an attacker on the path could.
+type spoofingZones struct {
+	*fakeZones
+	spoofed, with string
+}
+
+func (s *spoofingZones) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	q := m.Question[0]
+	if q.Qtype != dns.TypeTXT || dns.CanonicalName(q.Name) != s.spoofed {
+		return s.fakeZones.Exchange(m)
+	}
+	spoof := m.Copy()
+	spoof.Question[0].Name = s.with
+	r, err := s.fakeZones.Exchange(spoof)
+	if err != nil {
+		return nil, err
+	}
+	r.Question = m.Question
+	return r, nil
+}
+
+func (z *testZone) sign(t *testing.T, rrset ...dns.RR) []dns.RR {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Algorithm:  z.key.Algorithm,
+		SignerName: z.key.Hdr.Name,
+		KeyTag:     z.key.KeyTag(),
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(z.private, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrset, sig)
+}
+
+func txtRecord(name, value string) *dns.TXT {
+	return &dns.TXT{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+		Txt: []string{value},
+	}
+}
+
+func TestValidator(t *testing.T) {
+	root := newTestZone(t, ".")
+	example := newTestZone(t, "example.")
+	rogue := newTestZone(t, "example.")
+
+	zones := &fakeZones{}
+	zones.records = append(zones.records, root.sign(t, root.key)...)
+	zones.records = append(zones.records, example.sign(t, example.key)...)
+	zones.records = append(zones.records, root.sign(t, example.key.ToDS(dns.SHA256))...)
+	zones.records = append(zones.records, example.sign(t, txtRecord("good.example.", "v=DKIM1"))...)
+	zones.records = append(zones.records, rogue.sign(t, txtRecord("forged.example.", "v=DKIM1"))...)
+	// Stripping the signatures from a signed zone's records makes them bogus.
+	zones.records = append(zones.records, txtRecord("plain.example.", "v=DKIM1"))
+
+	// A zone with a valid chain can't sign another zone's records.
+	other := newTestZone(t, "other.")
+	zones.records = append(zones.records, other.sign(t, other.key)...)
+	zones.records = append(zones.records, root.sign(t, other.key.ToDS(dns.SHA256))...)
+	zones.records = append(zones.records, other.sign(t, txtRecord("victim.example.", "v=DKIM1"))...)
+
+	// Records from an unsigned zone are just that.
+	zones.records = append(zones.records, &dns.SOA{
+		Hdr: dns.RR_Header{Name: "insecure.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:  "ns.insecure.", Mbox: "admin.insecure.",
+	})
+	zones.records = append(zones.records, txtRecord("plain.insecure.", "v=DKIM1"))
+
+	// A signed CNAME leads to records elsewhere in the zone.
+	zones.records = append(zones.records, example.sign(t, &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "alias.example.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: "good.example.",
+	})...)
+
+	v, err := NewValidator(zones, &DNSSECConfig{TrustAnchors: []string{root.key.ToDS(dns.SHA256).String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for hostname, expected := range map[string]TxtResult{
+		"good.example.":   {Signed: true, Validated: true},
+		"forged.example.": {Signed: true, Validated: false},
+		"plain.example.":  {Signed: true, Validated: false},
+		"victim.example.": {Signed: true, Validated: false},
+		"plain.insecure.": {Signed: false, Validated: false},
+		"alias.example.":  {Signed: true, Validated: true},
+	} {
+		result, err := v.ResolveTxt(hostname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Records) != 1 || result.Signed != expected.Signed || result.Validated != expected.Validated {
+			t.Errorf("%s: got %+v, expected signed=%v validated=%v", hostname, result, expected.Signed, expected.Validated)
+		}
+	}
+
+	// An attacker can answer with records of their own, properly delegated
+	// zone; those are not records for the name asked about.
+	attacker := newTestZone(t, "attacker.")
+	zones.records = append(zones.records, attacker.sign(t, attacker.key)...)
+	zones.records = append(zones.records, root.sign(t, attacker.key.ToDS(dns.SHA256))...)
+	zones.records = append(zones.records, attacker.sign(t, txtRecord("x.attacker.", "v=DKIM1; p=evil"))...)
+	spoofed, err := NewValidator(&spoofingZones{fakeZones: zones, spoofed: "good.example.", with: "x.attacker."},
+		&DNSSECConfig{TrustAnchors: []string{root.key.ToDS(dns.SHA256).String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := spoofed.ResolveTxt("good.example."); err != nil || len(result.Records) != 0 || result.Validated {
+		t.Errorf("accepted records for another name: %+v, %v", result, err)
+	}
+
+	// Without the right anchor nothing validates.
+	otherRoot := newTestZone(t, ".")
+	v, err = NewValidator(zones, &DNSSECConfig{TrustAnchors: []string{otherRoot.key.ToDS(dns.SHA256).String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := v.ResolveTxt("good.example."); err != nil || result.Validated {
+		t.Errorf("validated against wrong anchor: %+v, %v", result, err)
+	}
+}
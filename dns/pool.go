@@ -0,0 +1,167 @@
+package dns
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// How long a resolver is avoided after failing, doubling with each
+// consecutive failure up to maxBackoff.
+const minBackoff = 5 * time.Second
+const maxBackoff = 5 * time.Minute
+
+type ResolverConfig struct {
+	Address string // host:port
+
+	// Use DNS-over-TLS, verifying the certificate for ServerName, or for the
+	// host in Address if empty.
+	TLS        bool   `json:",omitempty"`
+	ServerName string `json:",omitempty"`
+}
+
+type PoolConfig struct {
+	Resolvers      []*ResolverConfig
+	TimeoutSeconds int // per exchange; 0 means the default
+}
+
+// A Pool sends lookups to several resolvers. Lookups go to the first healthy
+// resolver, and are retried on the next if they fail. A Pool is threadsafe.
+type Pool struct {
+	mu        sync.Mutex
+	resolvers []*poolResolver
+}
+
+type poolResolver struct {
+	address string
+	client  *SimpleDNSClient
+
+	// protected by Pool.mu
+	failures  int
+	downUntil time.Time
+}
+
+// ResolverHealth describes how a resolver in a Pool has been doing.
+type ResolverHealth struct {
+	Address   string
+	Failures  int // consecutive
+	DownUntil time.Time
+}
+
+func NewPool(config *PoolConfig) (*Pool, error) {
+	if len(config.Resolvers) == 0 {
+		return nil, errors.New("no dns resolvers configured")
+	}
+
+	pool := &Pool{}
+	for _, resolver := range config.Resolvers {
+		client := &SimpleDNSClient{
+			Server:  resolver.Address,
+			Timeout: time.Duration(config.TimeoutSeconds) * time.Second,
+		}
+		if resolver.TLS {
+			serverName := resolver.ServerName
+			if serverName == "" {
+				host, _, err := net.SplitHostPort(resolver.Address)
+				if err != nil {
+					return nil, err
+				}
+				serverName = host
+			}
+			client.TLSConfig = &tls.Config{ServerName: serverName}
+		}
+
+		pool.resolvers = append(pool.resolvers, &poolResolver{
+			address: resolver.Address,
+			client:  client,
+		})
+	}
+	return pool, nil
+}
+
+// order returns the resolvers to try, healthy ones first and otherwise in
+// configured order.
+func (p *Pool) order() []*poolResolver {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	order := make([]*poolResolver, len(p.resolvers))
+	copy(order, p.resolvers)
+	sort.SliceStable(order, func(i, j int) bool {
+		return !now.Before(order[i].downUntil) && now.Before(order[j].downUntil)
+	})
+	return order
+}
+
+func (p *Pool) record(resolver *poolResolver, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		resolver.failures = 0
+		resolver.downUntil = time.Time{}
+		return
+	}
+
+	backoff := minBackoff << uint(resolver.failures)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	resolver.failures++
+	resolver.downUntil = time.Now().Add(backoff)
+}
+
+// Exchange sends m to each resolver in turn until one answers. With a single
+// resolver, a failed query is tried once more. Answers other than success and
+// NXDOMAIN are tried on the next resolver, but only transport errors and
+// timeouts count against a resolver's health: a SERVFAIL or REFUSED can just
+// as well come from the servers of the name queried.
+func (p *Pool) Exchange(m *dns.Msg) (*dns.Msg, error) {
+	order := p.order()
+	if len(order) == 1 {
+		order = append(order, order[0])
+	}
+
+	var lastErr error
+	for _, resolver := range order {
+		r, err := resolver.client.Exchange(m)
+		p.record(resolver, err)
+		if err == nil && r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			err = errors.New("dns query failed: " + dns.RcodeToString[r.Rcode])
+		}
+		if err == nil {
+			return r, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (p *Pool) ResolveTxt(hostname string) (*TxtResult, error) {
+	return resolveTxt(p, hostname)
+}
+
+func (p *Pool) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(p, hostname)
+}
+
+func (p *Pool) Health() []ResolverHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var health []ResolverHealth
+	for _, resolver := range p.resolvers {
+		health = append(health, ResolverHealth{
+			Address:   resolver.address,
+			Failures:  resolver.failures,
+			DownUntil: resolver.downUntil,
+		})
+	}
+	return health
+}
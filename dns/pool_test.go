@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// serve runs a DNS server on a local UDP port.
+func serve(t *testing.T, handler dns.HandlerFunc) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{
+		PacketConn: conn,
+		Handler:    handler,
+	}
+	go server.ActivateAndServe()
+	return conn.LocalAddr().String(), func() { server.Shutdown() }
+}
+
+// serveTxt runs a DNS server on a local UDP port that answers every TXT query
+// with record.
+func serveTxt(t *testing.T, record string) (string, func()) {
+	return serve(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+			Txt: []string{record},
+		})
+		w.WriteMsg(m)
+	})
+}
+
+func TestPoolFailover(t *testing.T) {
+	live, stop := serveTxt(t, "hello")
+	defer stop()
+
+	// Nothing listens on a just-closed port, so queries there fail.
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	pool, err := NewPool(&PoolConfig{
+		Resolvers:      []*ResolverConfig{{Address: deadAddr}, {Address: live}},
+		TimeoutSeconds: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := pool.ResolveTxt("example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 || result.Records[0] != "hello" || result.TTL.Seconds() != 300 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	health := pool.Health()
+	if health[0].Failures != 1 || health[1].Failures != 0 {
+		t.Errorf("unexpected health %+v", health)
+	}
+
+	// The failed resolver is now skipped in favor of the healthy one.
+	if order := pool.order(); order[0].address != live {
+		t.Errorf("expected %s first, got %s", live, order[0].address)
+	}
+}
+
+func TestPoolServfailKeepsHealth(t *testing.T) {
+	servfail, stopServfail := serve(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+	defer stopServfail()
+	live, stop := serveTxt(t, "hello")
+	defer stop()
+
+	pool, err := NewPool(&PoolConfig{
+		Resolvers:      []*ResolverConfig{{Address: servfail}, {Address: live}},
+		TimeoutSeconds: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The query still goes on to the next resolver...
+	result, err := pool.ResolveTxt("example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 || result.Records[0] != "hello" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// ...but the resolver that answered is not marked down.
+	if health := pool.Health(); health[0].Failures != 0 {
+		t.Errorf("unexpected health %+v", health)
+	}
+}
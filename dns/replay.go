@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// A Fixture holds recorded TXT answers by hostname. Fixtures are stored as
+// indented JSON, so they can be reviewed and edited by hand:
+//
+//	{
+//	  "Answers": {
+//	    "sel._domainkey.example.com.": {
+//	      "Records": ["v=DKIM1; k=rsa; p=..."],
+//	      "TTLSeconds": 300
+//	    }
+//	  }
+//	}
+type Fixture struct {
+	Answers map[string]*FixtureAnswer
+}
+
+type FixtureAnswer struct {
+	Records    []string
+	TTLSeconds uint32
+	NXDomain   bool `json:",omitempty"`
+	Signed     bool `json:",omitempty"`
+	Validated  bool `json:",omitempty"`
+}
+
+// fixtureKey makes lookups with and without a trailing dot, or in a
+// different case, find the same answer.
+func fixtureKey(hostname string) string {
+	return dns.CanonicalName(hostname)
+}
+
+func LoadFixture(path string) (*Fixture, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture *Fixture
+	if err := json.Unmarshal(bytes, &fixture); err != nil {
+		return nil, fmt.Errorf("couldn't parse fixture %s: %s", path, err)
+	}
+	return fixture, nil
+}
+
+func (f *Fixture) Save(path string) error {
+	bytes, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(bytes, '\n'), 0644)
+}
+
+// A Recorder passes lookups to an underlying resolver and records the
+// answers. Failed lookups are not recorded. A Recorder is threadsafe.
+type Recorder struct {
+	underlying TxtResolver
+
+	mu      sync.Mutex
+	fixture *Fixture
+}
+
+func NewRecorder(underlying TxtResolver) *Recorder {
+	return &Recorder{
+		underlying: underlying,
+		fixture:    &Fixture{Answers: make(map[string]*FixtureAnswer)},
+	}
+}
+
+func (r *Recorder) ResolveTxt(hostname string) (*TxtResult, error) {
+	result, err := r.underlying.ResolveTxt(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Answers[fixtureKey(hostname)] = &FixtureAnswer{
+		Records:    result.Records,
+		TTLSeconds: uint32(result.TTL / time.Second),
+		NXDomain:   result.NXDomain,
+		Signed:     result.Signed,
+		Validated:  result.Validated,
+	}
+	return result, nil
+}
+
+func (r *Recorder) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(r, hostname)
+}
+
+// Save writes all answers recorded so far to path.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.fixture.Save(path)
+}
+
+// A ReplayClient answers lookups from a fixture. Lookups of hostnames not in
+// the fixture fail, so tests notice when they would need the network.
+type ReplayClient struct {
+	fixture *Fixture
+}
+
+func NewReplayClient(fixture *Fixture) *ReplayClient {
+	return &ReplayClient{fixture: fixture}
+}
+
+func (c *ReplayClient) ResolveTxt(hostname string) (*TxtResult, error) {
+	answer, found := c.fixture.Answers[fixtureKey(hostname)]
+	if !found {
+		return nil, fmt.Errorf("no recorded answer for %s", hostname)
+	}
+
+	return &TxtResult{
+		Records:   answer.Records,
+		TTL:       time.Duration(answer.TTLSeconds) * time.Second,
+		NXDomain:  answer.NXDomain,
+		Signed:    answer.Signed,
+		Validated: answer.Validated,
+	}, nil
+}
+
+func (c *ReplayClient) LookupTxt(hostname string) ([]string, error) {
+	return lookupTxt(c, hostname)
+}
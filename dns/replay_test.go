@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	resolver := &fakeResolver{results: map[string]*TxtResult{
+		"sel._domainkey.example.com.": {Records: []string{"v=DKIM1; p=abc"}, TTL: time.Hour, Signed: true},
+		"gone.example.com.":           {NXDomain: true, TTL: time.Minute},
+	}}
+	recorder := NewRecorder(resolver)
+
+	if _, err := recorder.LookupTxt("sel._domainkey.example.com."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.LookupTxt("gone.example.com."); err != ErrNXDomain {
+		t.Fatalf("expected NXDOMAIN; got %v", err)
+	}
+	if _, err := recorder.LookupTxt("missing.example.com."); err == nil {
+		t.Fatalf("expected failed lookup")
+	}
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dns.json")
+
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewReplayClient(fixture)
+
+	// Lookups match regardless of case and trailing dot.
+	result, err := client.ResolveTxt("SEL._domainkey.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 || result.Records[0] != "v=DKIM1; p=abc" || result.TTL != time.Hour || !result.Signed {
+		t.Errorf("replayed %v", result)
+	}
+	if _, err := client.LookupTxt("gone.example.com."); err != ErrNXDomain {
+		t.Errorf("expected replayed NXDOMAIN; got %v", err)
+	}
+	if _, err := client.LookupTxt("missing.example.com."); err == nil {
+		t.Errorf("expected failed lookup of unrecorded hostname")
+	}
+}
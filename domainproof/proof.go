@@ -0,0 +1,93 @@
+package domainproof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jellevandenhooff/dkim"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// TokenHostname returns the hostname whose TXT records prove ownership of
+// domain.
+func TokenHostname(domain string) string {
+	return "_keytree." + domain + "."
+}
+
+// TokenRecord returns the TXT record that proves ownership of a domain for
+// the update with the given token.
+func TokenRecord(token string) string {
+	return "keytree-token=" + token
+}
+
+func EncodeAttestation(attestation *wire.SignedDomainAttestation) (string, error) {
+	bytes, err := json.Marshal(attestation)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func DecodeAttestation(signature string) (*wire.SignedDomainAttestation, error) {
+	var attestation *wire.SignedDomainAttestation
+	if err := json.Unmarshal([]byte(signature), &attestation); err != nil {
+		return nil, err
+	}
+	if err := attestation.Check(); err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
+
+// CheckAttestation verifies that signature is an encoded attestation for
+// statement signed by one of the trusted server keys. The TXT record may be
+// gone by the time peers replay the update, so they rely on the attesting
+// server instead.
+func CheckAttestation(signature string, statement *wire.DomainStatement, trusted map[string]bool) error {
+	attestation, err := DecodeAttestation(signature)
+	if err != nil {
+		return fmt.Errorf("could not decode attestation: %s", err)
+	}
+
+	if !trusted[attestation.PublicKey] {
+		return errors.New("attestation signed by untrusted key")
+	}
+
+	if err := crypto.Verify(attestation.PublicKey, attestation.Attestation, attestation.Signature); err != nil {
+		return err
+	}
+
+	attested := attestation.Attestation.Statement
+	if attested.Domain != statement.Domain {
+		return fmt.Errorf("incorrect domain '%s', expecting '%s'", attested.Domain, statement.Domain)
+	}
+	if attested.Token != statement.Token {
+		return fmt.Errorf("incorrect token '%s', expecting '%s'", attested.Token, statement.Token)
+	}
+
+	return nil
+}
+
+// CheckRecord looks up the TXT records for statement's domain and checks that
+// one of them holds its token.
+func CheckRecord(statement *wire.DomainStatement, dnsClient dkim.DNSClient) error {
+	if dnsClient == nil {
+		return errors.New("no dns client")
+	}
+
+	hostname := TokenHostname(statement.Domain)
+	records, err := dnsClient.LookupTxt(hostname)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record == TokenRecord(statement.Token) {
+			return nil
+		}
+	}
+	return fmt.Errorf("missing TXT record '%s' at %s", TokenRecord(statement.Token), hostname)
+}
@@ -0,0 +1,69 @@
+package domainproof
+
+import (
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+type fakeDNSClient map[string][]string
+
+func (f fakeDNSClient) LookupTxt(hostname string) ([]string, error) {
+	return f[hostname], nil
+}
+
+func TestAttestationRoundtrip(t *testing.T) {
+	public, private := crypto.GenerateRandomEd25519Keypair()
+	signer, err := crypto.NewSigner(private)
+	if err != nil {
+		t.Fatalf("unexpected error creating signer: %s", err)
+	}
+
+	statement := &wire.DomainStatement{
+		Domain: "example.com",
+		Token:  "abcdef",
+	}
+	attestation := &wire.DomainAttestation{
+		Statement: statement,
+		Timestamp: 1000,
+	}
+
+	signature, err := EncodeAttestation(&wire.SignedDomainAttestation{
+		Attestation: attestation,
+		PublicKey:   public,
+		Signature:   signer.Sign(attestation),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error encoding: %s", err)
+	}
+
+	if err := CheckAttestation(signature, statement, map[string]bool{public: true}); err != nil {
+		t.Errorf("unexpected error checking: %s", err)
+	}
+
+	if err := CheckAttestation(signature, statement, map[string]bool{}); err == nil {
+		t.Errorf("expected error for untrusted key")
+	}
+
+	other := &wire.DomainStatement{
+		Domain: "example.org",
+		Token:  "abcdef",
+	}
+	if err := CheckAttestation(signature, other, map[string]bool{public: true}); err == nil {
+		t.Errorf("expected error for other domain")
+	}
+}
+
+func TestCheckRecord(t *testing.T) {
+	dnsClient := fakeDNSClient{
+		"_keytree.example.com.": []string{"v=spf1 -all", "keytree-token=abcdef"},
+	}
+
+	if err := CheckRecord(&wire.DomainStatement{Domain: "example.com", Token: "abcdef"}, dnsClient); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := CheckRecord(&wire.DomainStatement{Domain: "example.com", Token: "ghijkl"}, dnsClient); err == nil {
+		t.Errorf("expected error for missing token")
+	}
+}
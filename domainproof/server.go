@@ -0,0 +1,63 @@
+package domainproof
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jellevandenhooff/dkim"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/unixtime"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+type Server struct {
+	publicKey string
+	signer    *crypto.Signer
+	dnsClient dkim.DNSClient
+}
+
+func NewServer(publicKey string, signer *crypto.Signer, dnsClient dkim.DNSClient) *Server {
+	return &Server{
+		publicKey: publicKey,
+		signer:    signer,
+		dnsClient: dnsClient,
+	}
+}
+
+func (s *Server) handleAttest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var req wire.DomainStatement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := CheckRecord(&req, s.dnsClient); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	attestation := &wire.DomainAttestation{
+		Statement: &req,
+		Timestamp: unixtime.Now(),
+	}
+
+	wire.ReplyJSON(w, &wire.SignedDomainAttestation{
+		Attestation: attestation,
+		PublicKey:   s.publicKey,
+		Signature:   s.signer.Sign(attestation),
+	})
+}
+
+func (s *Server) AddHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/domain/attest", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAttest(w, r)
+	})
+}
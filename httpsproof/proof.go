@@ -0,0 +1,65 @@
+package httpsproof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+const WellKnownPrefix = "/.well-known/keytree/"
+
+// URLForStatement returns the location the server fetches to check a
+// statement. The file must contain only the token.
+func URLForStatement(statement *wire.HTTPSStatement) string {
+	return "https://" + statement.Origin + WellKnownPrefix + statement.Token
+}
+
+func EncodeAttestation(attestation *wire.SignedHTTPSAttestation) (string, error) {
+	bytes, err := json.Marshal(attestation)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func DecodeAttestation(signature string) (*wire.SignedHTTPSAttestation, error) {
+	var attestation *wire.SignedHTTPSAttestation
+	if err := json.Unmarshal([]byte(signature), &attestation); err != nil {
+		return nil, err
+	}
+	if err := attestation.Check(); err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
+
+// CheckAttestation verifies that signature is an encoded attestation for
+// statement signed by one of the trusted server keys. The fetch itself can't
+// be repeated by peers, so they rely on the attesting server instead.
+func CheckAttestation(signature string, statement *wire.HTTPSStatement, trusted map[string]bool) error {
+	attestation, err := DecodeAttestation(signature)
+	if err != nil {
+		return fmt.Errorf("could not decode attestation: %s", err)
+	}
+
+	if !trusted[attestation.PublicKey] {
+		return errors.New("attestation signed by untrusted key")
+	}
+
+	if err := crypto.Verify(attestation.PublicKey, attestation.Attestation, attestation.Signature); err != nil {
+		return err
+	}
+
+	attested := attestation.Attestation.Statement
+	if attested.Origin != statement.Origin {
+		return fmt.Errorf("incorrect origin '%s', expecting '%s'", attested.Origin, statement.Origin)
+	}
+	if attested.Token != statement.Token {
+		return fmt.Errorf("incorrect token '%s', expecting '%s'", attested.Token, statement.Token)
+	}
+
+	return nil
+}
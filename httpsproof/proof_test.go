@@ -0,0 +1,81 @@
+package httpsproof
+
+import (
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func TestAttestationRoundtrip(t *testing.T) {
+	public, private := crypto.GenerateRandomEd25519Keypair()
+	signer, err := crypto.NewSigner(private)
+	if err != nil {
+		t.Fatalf("unexpected error creating signer: %s", err)
+	}
+
+	statement := &wire.HTTPSStatement{
+		Origin: "example.com",
+		Token:  "abcdef",
+	}
+	attestation := &wire.HTTPSAttestation{
+		Statement: statement,
+		Timestamp: 1000,
+	}
+
+	signature, err := EncodeAttestation(&wire.SignedHTTPSAttestation{
+		Attestation: attestation,
+		PublicKey:   public,
+		Signature:   signer.Sign(attestation),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error encoding: %s", err)
+	}
+
+	if err := CheckAttestation(signature, statement, map[string]bool{public: true}); err != nil {
+		t.Errorf("unexpected error checking: %s", err)
+	}
+
+	if err := CheckAttestation(signature, statement, map[string]bool{}); err == nil {
+		t.Errorf("expected error for untrusted key")
+	}
+
+	other := &wire.HTTPSStatement{
+		Origin: "example.org",
+		Token:  "abcdef",
+	}
+	if err := CheckAttestation(signature, other, map[string]bool{public: true}); err == nil {
+		t.Errorf("expected error for wrong origin")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	for _, origin := range []string{"example.com", "keytree-test.example.org", "xn--bcher-kva.example"} {
+		if err := checkOrigin(origin); err != nil {
+			t.Errorf("unexpected error for %s: %s", origin, err)
+		}
+	}
+	for _, origin := range []string{"", "example.com:22", "10.0.0.1", "169.254.169.254", "[::1]", "example.com/x", "a@example.com", "Example.com", "example..com"} {
+		if err := checkOrigin(origin); err == nil {
+			t.Errorf("expected error for %q", origin)
+		}
+	}
+
+	if err := checkToken("abc123"); err != nil {
+		t.Errorf("unexpected error for token: %s", err)
+	}
+	if err := checkToken("../x"); err == nil {
+		t.Errorf("expected error for token with path characters")
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:443", "10.1.2.3:443", "192.168.0.1:443", "169.254.169.254:80", "[::1]:443", "[fe80::1]:443", "0.0.0.0:443"} {
+		if err := refusePrivate("tcp", address, nil); err == nil {
+			t.Errorf("expected %s to be refused", address)
+		}
+	}
+	if err := refusePrivate("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("unexpected error for public address: %s", err)
+	}
+}
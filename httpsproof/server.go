@@ -0,0 +1,188 @@
+package httpsproof
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/unixtime"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+const maxTokenFileSize = 1024
+
+const maxTokenLength = 64
+
+// checkOrigin accepts only bare DNS hostnames: no port, no IP literal and no
+// characters that could change how the URL is parsed.
+func checkOrigin(origin string) error {
+	if origin == "" || len(origin) > 253 {
+		return errors.New("bad origin")
+	}
+	if net.ParseIP(origin) != nil {
+		return errors.New("origin must be a hostname, not an ip address")
+	}
+	for _, label := range strings.Split(origin, ".") {
+		if label == "" || len(label) > 63 {
+			return errors.New("bad origin")
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("bad character '%c' in origin", c)
+			}
+		}
+	}
+	return nil
+}
+
+func checkToken(token string) error {
+	if token == "" || len(token) > maxTokenLength {
+		return errors.New("bad token length")
+	}
+	for _, c := range token {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return fmt.Errorf("bad character '%c' in token", c)
+		}
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is reachable on the public internet, so that
+// attestation requests can't be pointed at the server's own network.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// refusePrivate is a net.Dialer Control function. It runs after name
+// resolution, so hostnames resolving to private addresses are refused too.
+func refusePrivate(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("refusing to connect to %s", host)
+	}
+	return nil
+}
+
+type Server struct {
+	publicKey  string
+	signer     *crypto.Signer
+	httpClient *http.Client
+}
+
+func NewServer(publicKey string, signer *crypto.Signer) *Server {
+	return &Server{
+		publicKey: publicKey,
+		signer:    signer,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout: 10 * time.Second,
+					Control: refusePrivate,
+				}).DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// Redirects could point at a different origin, so don't follow
+			// them.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (s *Server) fetchToken(statement *wire.HTTPSStatement) error {
+	if err := checkOrigin(statement.Origin); err != nil {
+		return err
+	}
+	if err := checkToken(statement.Token); err != nil {
+		return err
+	}
+	location := URLForStatement(statement)
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	if u.Host != statement.Origin || u.User != nil || u.Path != WellKnownPrefix+statement.Token {
+		return errors.New("bad origin")
+	}
+
+	// Don't tell callers why a fetch failed; that would let them probe
+	// hosts through us.
+	resp, err := s.httpClient.Get(location)
+	if err != nil {
+		log.Printf("fetching %s failed: %s\n", location, err)
+		return fmt.Errorf("could not fetch %s", location)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("fetching %s failed: %s\n", location, resp.Status)
+		return fmt.Errorf("could not fetch %s", location)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTokenFileSize))
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(body)) != statement.Token {
+		return fmt.Errorf("%s does not contain token '%s'", location, statement.Token)
+	}
+
+	return nil
+}
+
+func (s *Server) handleAttest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var req wire.HTTPSStatement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.fetchToken(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	attestation := &wire.HTTPSAttestation{
+		Statement: &req,
+		Timestamp: unixtime.Now(),
+	}
+
+	wire.ReplyJSON(w, &wire.SignedHTTPSAttestation{
+		Attestation: attestation,
+		PublicKey:   s.publicKey,
+		Signature:   s.signer.Sign(attestation),
+	})
+}
+
+func (s *Server) AddHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/https/attest", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAttest(w, r)
+	})
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
-	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/domainproof"
+	"github.com/jellevandenhooff/keytree/httpsproof"
+	"github.com/jellevandenhooff/keytree/names"
 	"github.com/jellevandenhooff/keytree/rules"
 	"github.com/jellevandenhooff/keytree/trie"
 	"github.com/jellevandenhooff/keytree/unixtime"
@@ -22,11 +27,75 @@ import (
 
 var server = flag.String("server", "keytree.io", "URL of Keytree server")
 
+var keygen = flag.String("keygen", "", "Generate a guardian key and store its private key in `file`.")
+var sign = flag.String("sign", "", "Sign the entry `file` of a signing round as a guardian.")
+var keyFile = flag.String("key", "", "Private key `file` to sign with.")
+var deleteRecord = flag.Bool("delete", false, "Delete the record instead of updating it.")
+var cancelRecovery = flag.Bool("cancel-recovery", false, "Take a locked record out of recovery without changing it.")
+var lookup = flag.Bool("lookup", false, "Look up the record and print its currently valid keys.")
+var validFor = flag.Duration("valid-for", 0, "Limit the keys set by this update to be valid for `duration`, e.g. 2160h.")
+var domainKey = flag.String("domain-key", "", "Sign an email record with the domain admin private key in `file` instead of proving ownership by e-mail.")
+var oidcCommand = flag.String("oidc-command", "", "Prove ownership of an email record with the OpenID ID token printed by `command`, which is passed the nonce as its last argument, instead of by e-mail.")
+var roundDir = flag.String("round", "keytree-round", "Directory in which to exchange entries and signatures with guardians.")
+
 func usage() {
-	fmt.Printf("Usage: %s [update|lookup] [flags] <name> [key=value]...\nFlags:\n", os.Args[0])
+	fmt.Printf("Usage: %s [flags] <name> [key=value]...\nFlags:\n", os.Args[0])
 	flag.PrintDefaults()
 }
 
+func verifiedLookup(conn *wire.KeyTreeClient, publicKeys []string, name string) (*wire.Entry, error) {
+	reply, err := conn.Lookup(crypto.HashString(name))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, publicKey := range publicKeys {
+		signedTrieLookup, found := reply.SignedTrieLookups[publicKey]
+		if !found {
+			return nil, errors.New("lookup missing")
+		}
+
+		signedRoot := signedTrieLookup.SignedRoot
+
+		if err := crypto.Verify(publicKey, signedRoot.Root, signedRoot.Signature); err != nil {
+			return nil, err
+		}
+
+		if signedRoot.Root.Timestamp < unixtime.Now()-20 || signedRoot.Root.Timestamp > unixtime.Now()+20 {
+			return nil, errors.New("signature time out of range!")
+		}
+
+		rootHash := trie.CompleteLookup(signedTrieLookup.TrieLookup, crypto.HashString(name), reply.Entry.Hash())
+
+		if rootHash != signedRoot.Root.RootHash {
+			return nil, errors.New("lookup does not match signed root")
+		}
+	}
+
+	return reply.Entry, nil
+}
+
+// printValidKeys prints the keys of entry that are currently valid. Keys
+// outside their validity window are left out.
+func printValidKeys(name string, entry *wire.Entry) {
+	if entry == nil {
+		fmt.Printf("There is no Keytree record for '%s'.\n", name)
+		return
+	}
+
+	keys := entry.ValidKeys(unixtime.Now())
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Keytree record for '%s':\n", name)
+	for _, name := range names {
+		fmt.Printf("%s=%s\n", name, keys[name])
+	}
+}
+
 func main() {
 	publicKeys := []string{
 		//"ed25519-pub(xmmqz7cvgdd9ewa79vw9cw9qvemyd4x3zsaftacc2jqqm4nfzw20)",
@@ -36,14 +105,36 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if *keygen != "" {
+		if err := guardianKeygen(*keygen); err != nil {
+			log.Panicln(err)
+		}
+		return
+	}
+
+	if *sign != "" {
+		if *keyFile == "" {
+			usage()
+			os.Exit(1)
+		}
+		if err := guardianSign(*sign, *keyFile); err != nil {
+			log.Panicln(err)
+		}
+		return
+	}
+
 	if flag.NArg() < 1 {
 		usage()
 		os.Exit(1)
 	}
 	name := flag.Arg(0)
-	if !strings.HasPrefix(name, "email:") && !strings.HasPrefix(name, "test:") {
+	if !strings.HasPrefix(name, "email:") && !strings.HasPrefix(name, "https:") && !strings.HasPrefix(name, "domain:") && !strings.HasPrefix(name, "test:") {
 		name = "email:" + name
 	}
+	name, err := names.NormalizeName(name)
+	if err != nil {
+		log.Panicln(err)
+	}
 
 	conn := wire.NewKeyTreeClient("http://" + *server)
 
@@ -61,44 +152,34 @@ func main() {
 		newKeys[arg[:idx]] = arg[idx+1:]
 	}
 
-	fmt.Printf("Updating Keytree record for '%s'.\n", name)
-
-	var old *wire.Entry
+	if (*deleteRecord || *cancelRecovery) && len(newKeys) > 0 {
+		usage()
+		os.Exit(1)
+	}
 
-	{
-		reply, err := conn.Lookup(crypto.HashString(name))
+	if *lookup {
+		old, err := verifiedLookup(conn, publicKeys, name)
 		if err != nil {
 			log.Panicln(err)
 		}
+		printValidKeys(name, old)
+		return
+	}
 
-		var checkedSignatures []string
-
-		for _, publicKey := range publicKeys {
-			signedTrieLookup, found := reply.SignedTrieLookups[publicKey]
-			if !found {
-				log.Panicln("lookup missing")
-			}
-
-			signedRoot := signedTrieLookup.SignedRoot
-
-			if err := crypto.Verify(publicKey, signedRoot.Root, signedRoot.Signature); err != nil {
-				log.Panicln(err)
-			}
-
-			if signedRoot.Root.Timestamp < unixtime.Now()-20 || signedRoot.Root.Timestamp > unixtime.Now()+20 {
-				log.Panicln("signature time out of range!")
-			}
-
-			rootHash := trie.CompleteLookup(signedTrieLookup.TrieLookup, crypto.HashString(name), reply.Entry.Hash())
-
-			if rootHash != signedRoot.Root.RootHash {
-				log.Panicln("lookup does not match signed root")
-			}
+	if *deleteRecord {
+		fmt.Printf("Deleting Keytree record for '%s'.\n", name)
+	} else {
+		fmt.Printf("Updating Keytree record for '%s'.\n", name)
+	}
 
-			checkedSignatures = append(checkedSignatures, publicKey)
-		}
+	old, err := verifiedLookup(conn, publicKeys, name)
+	if err != nil {
+		log.Panicln(err)
+	}
 
-		old = reply.Entry
+	if *deleteRecord && old == nil {
+		fmt.Printf("There is no Keytree record for '%s'.\n", name)
+		os.Exit(1)
 	}
 
 	newEntry := &wire.Entry{
@@ -106,6 +187,7 @@ func main() {
 		Keys:       make(map[string]string),
 		Timestamp:  unixtime.Now(),
 		InRecovery: false,
+		Deleted:    *deleteRecord,
 	}
 
 	var oldPublic, oldPrivate string
@@ -121,40 +203,81 @@ func main() {
 		}
 	}
 
-	var newPublic string
-	fmt.Printf("Enter a password to lock your Keytree record, or leave empty for no lock: ")
-	pwbytes, _ := terminal.ReadPassword(syscall.Stdin)
-	fmt.Println()
-	password := string(pwbytes)
-
-	if password != "" {
-		fmt.Printf("Please repeat the password: ")
-		repeated, _ := terminal.ReadPassword(syscall.Stdin)
-		fmt.Println()
-		if password != string(repeated) {
-			fmt.Printf("Passwords did not match!\n")
+	if *cancelRecovery {
+		if old == nil || !old.InRecovery {
+			fmt.Printf("This Keytree record is not in recovery.\n")
+			os.Exit(1)
+		}
+		if oldPublic == "" {
+			fmt.Printf("Only locked Keytree records can cancel recovery.\n")
 			os.Exit(1)
 		}
-		newPublic, _ = crypto.GenerateEd25519KeypairFromSecret(password, name)
-	}
 
-	if old != nil {
 		for k, v := range old.Keys {
 			newEntry.Keys[k] = v
 		}
-	}
-	for k, v := range newKeys {
-		if v == "" {
-			delete(newEntry.Keys, k)
-		} else {
-			newEntry.Keys[k] = v
+		newEntry.KeyValidity = old.KeyValidity
+		signature, _ := crypto.Sign(oldPrivate, newEntry)
+
+		fmt.Printf("Submitting update to Keytree server...\n")
+		if err := conn.Submit(&wire.SignedEntry{
+			Entry:      newEntry,
+			Signatures: map[string]string{oldPublic: signature},
+		}); err != nil {
+			log.Panicln(err)
 		}
+
+		fmt.Printf("Successfully cancelled recovery.\n")
+		return
 	}
 
-	if newPublic == "" {
-		delete(newEntry.Keys, "keytree:recovery")
-	} else {
-		newEntry.Keys["keytree:recovery"] = newPublic
+	var newPublic string
+	if !*deleteRecord {
+		fmt.Printf("Enter a password to lock your Keytree record, or leave empty for no lock: ")
+		pwbytes, _ := terminal.ReadPassword(syscall.Stdin)
+		fmt.Println()
+		password := string(pwbytes)
+
+		if password != "" {
+			fmt.Printf("Please repeat the password: ")
+			repeated, _ := terminal.ReadPassword(syscall.Stdin)
+			fmt.Println()
+			if password != string(repeated) {
+				fmt.Printf("Passwords did not match!\n")
+				os.Exit(1)
+			}
+			newPublic, _ = crypto.GenerateEd25519KeypairFromSecret(password, name)
+		}
+
+		newEntry.KeyValidity = make(map[string]*wire.Validity)
+		if old != nil {
+			for k, v := range old.Keys {
+				newEntry.Keys[k] = v
+			}
+			for k, v := range old.KeyValidity {
+				newEntry.KeyValidity[k] = v
+			}
+		}
+		for k, v := range newKeys {
+			delete(newEntry.KeyValidity, k)
+			if v == "" {
+				delete(newEntry.Keys, k)
+			} else {
+				newEntry.Keys[k] = v
+				if *validFor > 0 {
+					newEntry.KeyValidity[k] = &wire.Validity{
+						NotAfter: newEntry.Timestamp + uint64(validFor.Seconds()),
+					}
+				}
+			}
+		}
+
+		delete(newEntry.KeyValidity, "keytree:recovery")
+		if newPublic == "" {
+			delete(newEntry.Keys, "keytree:recovery")
+		} else {
+			newEntry.Keys["keytree:recovery"] = newPublic
+		}
 	}
 
 	signatures := make(map[string]string)
@@ -162,10 +285,42 @@ func main() {
 		signatures[oldPublic], _ = crypto.Sign(oldPrivate, newEntry)
 	}
 
+	hasGuardianQuorum := false
+	if old != nil {
+		if threshold := rules.Threshold(old); threshold > 0 {
+			if err := collectGuardianSignatures(*roundDir, old, newEntry, threshold, signatures); err != nil {
+				log.Panicln(err)
+			}
+			hasGuardianQuorum = true
+		}
+	}
+
 	// todo: determine if we need a proof of ownership!
-	if len(signatures) == 0 || newPublic != oldPublic {
+	// A recovery signature suffices to delete a locked record.
+	if len(signatures) == 0 || (newPublic != oldPublic && !hasGuardianQuorum && !*deleteRecord) {
 		token := rules.TokenForEntry(newEntry)
-		if strings.HasPrefix(name, "email:") {
+		if strings.HasPrefix(name, "email:") && *domainKey != "" {
+			keyBytes, err := ioutil.ReadFile(*domainKey)
+			if err != nil {
+				log.Panicln(err)
+			}
+			private := strings.TrimSpace(string(keyBytes))
+			public, err := crypto.PublicKeyForPrivateKey(private)
+			if err != nil {
+				log.Panicln(err)
+			}
+			signatures[public], err = crypto.Sign(private, newEntry)
+			if err != nil {
+				log.Panicln(err)
+			}
+		} else if strings.HasPrefix(name, "email:") && *oidcCommand != "" {
+			fmt.Printf("Obtaining ID token with nonce %s...\n", token)
+			output, err := exec.Command("/bin/sh", "-c", *oidcCommand+" "+token).Output()
+			if err != nil {
+				log.Panicln(err)
+			}
+			signatures["oidc"] = strings.TrimSpace(string(output))
+		} else if strings.HasPrefix(name, "email:") {
 			statement := &wire.DKIMStatement{
 				Sender: strings.TrimPrefix(name, "email:"),
 				Token:  token,
@@ -192,11 +347,9 @@ func main() {
 			fmt.Printf("Waiting for e-mail...\n")
 
 			idx := 0
-			proof := ""
+			proof, proofType := "", ""
 			for proof == "" {
-				time.Sleep(1 * time.Second)
-
-				reply, err := dkimConn.Poll(email)
+				reply, err := dkimConn.Wait(email, idx)
 				if err != nil {
 					log.Panicln(err)
 				}
@@ -205,10 +358,62 @@ func main() {
 					fmt.Printf("%s\n", reply.Status[idx])
 				}
 
-				proof = reply.Proof
+				proof, proofType = reply.Proof, reply.ProofType
+			}
+
+			if proofType == "" {
+				proofType = "dkim"
+			}
+			signatures[proofType] = proof
+		}
+
+		if strings.HasPrefix(name, "https:") {
+			statement := &wire.HTTPSStatement{
+				Origin: strings.TrimPrefix(name, "https:"),
+				Token:  token,
+			}
+
+			fmt.Printf("To verify ownership of %s, serve a file at %s containing only %s.\n", statement.Origin, httpsproof.URLForStatement(statement), statement.Token)
+			fmt.Printf("Press enter once the file is in place...")
+			fmt.Scanln()
+
+			httpsConn := wire.NewHTTPSClient("http://" + *server)
+
+			fmt.Printf("Obtaining HTTPS attestation for new entry...\n")
+			attestation, err := httpsConn.Attest(statement)
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			signature, err := httpsproof.EncodeAttestation(attestation)
+			if err != nil {
+				log.Panicln(err)
 			}
+			signatures["https"] = signature
+		}
 
-			signatures["dkim"] = proof
+		if strings.HasPrefix(name, "domain:") {
+			domain := strings.TrimPrefix(name, "domain:")
+			fmt.Printf("To verify ownership of %s, add a TXT record at %s with value %s.\n", domain, domainproof.TokenHostname(domain), domainproof.TokenRecord(token))
+			fmt.Printf("The update expires 15 minutes after it was created. Press enter once the record is in place...")
+			fmt.Scanln()
+
+			domainConn := wire.NewDomainClient("http://" + *server)
+
+			fmt.Printf("Obtaining DNS attestation for new entry...\n")
+			attestation, err := domainConn.Attest(&wire.DomainStatement{
+				Domain: domain,
+				Token:  token,
+			})
+			if err != nil {
+				log.Panicln(err)
+			}
+
+			signature, err := domainproof.EncodeAttestation(attestation)
+			if err != nil {
+				log.Panicln(err)
+			}
+			signatures["domain"] = signature
 		}
 
 		if strings.HasPrefix(name, "test:") {
@@ -227,6 +432,11 @@ func main() {
 		}
 	}
 
+	if *deleteRecord {
+		fmt.Printf("Successfully deleted record.\n")
+		return
+	}
+
 	fmt.Printf("Successfully applied update.\n")
 
 	if newPublic == "" {
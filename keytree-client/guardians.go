@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// A guardianSignature is a detached signature on an entry, exchanged through
+// files during a signing round.
+type guardianSignature struct {
+	PublicKey string
+	Signature string
+}
+
+const roundEntryName = "entry.json"
+
+func writeJSON(path string, v interface{}, perm os.FileMode) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, perm)
+}
+
+func readJSON(path string, v interface{}) error {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// guardianKeygen creates a new guardian keypair, storing the private key at
+// path.
+func guardianKeygen(path string) error {
+	public, private := crypto.GenerateRandomEd25519Keypair()
+	if err := ioutil.WriteFile(path, []byte(private), 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Stored guardian private key in %s.\n", path)
+	fmt.Printf("Add it to a record as a key named keytree:<guardian>, for example:\n  keytree:guardian=%s\n", public)
+	return nil
+}
+
+// guardianSign signs the entry of a signing round with the private key at
+// keyPath, and stores the signature next to the entry.
+func guardianSign(entryPath, keyPath string) error {
+	var entry wire.Entry
+	if err := readJSON(entryPath, &entry); err != nil {
+		return err
+	}
+
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	private := strings.TrimSpace(string(keyBytes))
+	// Derive the public key rather than finding it in the new entry, so that
+	// guardians being removed by the update can sign it too.
+	public, err := crypto.PublicKeyForPrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Signing update for '%s' with keys:\n", entry.Name)
+	names := make([]string, 0, len(entry.Keys))
+	for name := range entry.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %s=%s\n", name, entry.Keys[name])
+	}
+	fmt.Printf("Type 'yes' to sign: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		return errors.New("not signing")
+	}
+
+	signature, err := crypto.Sign(private, &entry)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(filepath.Dir(entryPath), crypto.HashString(public).String()[:16]+".sig")
+	if err := writeJSON(path, &guardianSignature{PublicKey: public, Signature: signature}, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Stored signature in %s.\n", path)
+	return nil
+}
+
+// collectGuardianSignatures runs a signing round in dir: it writes entry for
+// guardians to sign, waits, and adds every valid signature by one of old's
+// keytree keys to signatures. Signatures already in signatures, such as the
+// owner's, count towards threshold.
+func collectGuardianSignatures(dir string, old, entry *wire.Entry, threshold int, signatures map[string]string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	entryPath := filepath.Join(dir, roundEntryName)
+	if err := writeJSON(entryPath, entry, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("This record needs signatures from %d of its keytree keys.\n", threshold)
+	fmt.Printf("Ask the guardians to run '%s -sign %s -key <private key file>' and place the resulting .sig files in %s.\n", os.Args[0], entryPath, dir)
+	fmt.Printf("The update expires 15 minutes after it was created. Press enter once the signatures are in place...")
+	fmt.Scanln()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sig"))
+	if err != nil {
+		return err
+	}
+
+	// Like the server, count only keys valid when the update is made.
+	guardians := make(map[string]bool)
+	for name, key := range old.ValidKeys(entry.Timestamp) {
+		if strings.HasPrefix(name, "keytree:") {
+			guardians[key] = true
+		}
+	}
+
+	for _, path := range paths {
+		var signature guardianSignature
+		if err := readJSON(path, &signature); err != nil {
+			fmt.Printf("Skipping %s: %s\n", path, err)
+			continue
+		}
+		if !guardians[signature.PublicKey] {
+			fmt.Printf("Skipping %s: not signed by a keytree key of this record\n", path)
+			continue
+		}
+		if err := crypto.Verify(signature.PublicKey, entry, signature.Signature); err != nil {
+			fmt.Printf("Skipping %s: %s\n", path, err)
+			continue
+		}
+		signatures[signature.PublicKey] = signature.Signature
+	}
+
+	count := 0
+	for key := range guardians {
+		if signature, found := signatures[key]; found && crypto.Verify(key, entry, signature) == nil {
+			count += 1
+		}
+	}
+
+	if count < threshold {
+		return fmt.Errorf("collected %d valid signatures; need %d", count, threshold)
+	}
+	return nil
+}
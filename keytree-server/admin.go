@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/rules"
+	"github.com/jellevandenhooff/keytree/unixtime"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+type AdminDeleteRequest struct {
+	Name   string
+	Reason string
+}
+
+func (s *Server) checkAdmin(r *http.Request) bool {
+	if s.config.AdminToken == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1
+}
+
+// checkAdminDelete checks an admin tombstone against the current entry.
+// Admin tombstones carry the server's signature instead of a proof of
+// ownership, so peers will not accept them; they only affect this server.
+func checkAdminDelete(old *wire.Entry, update *wire.SignedEntry) error {
+	if !update.Entry.Deleted {
+		return errors.New("admin updates must be deletions")
+	}
+	if old == nil || old.Deleted {
+		return errors.New("can't delete a record that does not exist")
+	}
+	if old.Timestamp >= update.Entry.Timestamp {
+		return errors.New("record changed too recently; try again")
+	}
+	return nil
+}
+
+func (s *Server) handleAdminDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req AdminDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := rules.CheckName(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry := &wire.Entry{
+		Name:      req.Name,
+		Keys:      map[string]string{},
+		Timestamp: unixtime.Now(),
+		Deleted:   true,
+	}
+	update := &wire.SignedEntry{
+		Entry: entry,
+		Signatures: map[string]string{
+			"admin": s.signer.Sign(entry),
+		},
+	}
+
+	if err := s.doAdminUpdate(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record := &AuditRecord{
+		Timestamp:  entry.Timestamp,
+		Action:     "delete",
+		Name:       req.Name,
+		Reason:     req.Reason,
+		RemoteAddr: r.RemoteAddr,
+		EntryHash:  entry.Hash(),
+	}
+	if err := s.db.Audit(record); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wire.ReplyJSON(w, record)
+}
+
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	records, err := s.db.ReadAudit()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wire.ReplyJSON(w, records)
+}
+
+func (s *Server) handleAdminMail(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAdmin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if s.mailStore == nil {
+		http.Error(w, "mail store disabled", http.StatusNotFound)
+		return
+	}
+
+	mail, err := s.mailStore.Read(r.URL.Query().Get("id"))
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Write([]byte(mail))
+}
+
+// An AuditRecord describes an admin action.
+type AuditRecord struct {
+	Timestamp  uint64
+	Action     string
+	Name       string
+	Reason     string
+	RemoteAddr string
+	EntryHash  crypto.Hash
+}
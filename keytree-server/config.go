@@ -12,6 +12,10 @@ import (
 	"time"
 
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/dkimproof"
+	"github.com/jellevandenhooff/keytree/dns"
+	"github.com/jellevandenhooff/keytree/oidcproof"
+	"github.com/jellevandenhooff/keytree/rules"
 )
 
 const configName = "keytree-server.config"
@@ -37,6 +41,10 @@ const reconcileQueueSize = 2000
 type ServerInfo struct {
 	Address   string
 	PublicKey string
+
+	// Share of fetch requests during anti-entropy, relative to other
+	// upstream servers; 0 means 1.
+	FetchWeight int `json:",omitempty"`
 }
 
 type Config struct {
@@ -44,8 +52,45 @@ type Config struct {
 	PrivateKey string
 	Upstream   []ServerInfo
 	DNSServer  string
+	Policy     *rules.Policy
+
+	// Resolvers to use instead of DNSServer.
+	DNS *dns.PoolConfig `json:",omitempty"`
+	// If set, validate DNSSEC signatures on DKIM keys.
+	DNSSEC *dns.DNSSECConfig `json:",omitempty"`
+
+	// Most trie node requests outstanding at once during anti-entropy, over
+	// all upstream servers; 0 means mirror.DefaultFetchParallelism.
+	FetchParallelism int `json:",omitempty"`
+
+	// Token required by admin endpoints; if empty, they are disabled.
+	AdminToken string `json:",omitempty"`
+
+	// Hooks fired when a record enters recovery.
+	RecoveryHooks []HookConfig `json:",omitempty"`
+
+	// How to receive DKIM emails.
+	DKIM *dkimproof.Config
+
+	// OpenID providers whose ID tokens prove ownership of email addresses.
+	// Keep keys pinned after the provider rotates them, or peers can't
+	// verify older updates.
+	OIDCIssuers []*oidcproof.Issuer `json:",omitempty"`
+
+	// Where to keep received DKIM emails; if nil, they are not kept.
+	MailStore *MailStoreConfig `json:",omitempty"`
+}
+
+type MailStoreConfig struct {
+	Dir         string // relative to the data directory
+	MaxAgeHours int    // 0 means defaultMailMaxAgeHours
+	MaxMessages int    // 0 means defaultMailMaxMessages
 }
 
+// Received emails hold personal data, so they are not kept forever.
+const defaultMailMaxAgeHours = 30 * 24
+const defaultMailMaxMessages = 10000
+
 func parseDuration(duration string) (uint64, error) {
 	if len(duration) < 1 {
 		return 0, errors.New("missing suffix")
@@ -99,6 +144,8 @@ func writeDefaultConfig(path string) error {
 		PublicKey:  publicKey,
 		PrivateKey: privateKey,
 		DNSServer:  "8.8.4.4:53",
+		Policy:     rules.DefaultPolicy(),
+		DKIM:       dkimproof.DefaultConfig(),
 		Upstream: []ServerInfo{
 			{Address: "keytree.io", PublicKey: "ed25519-pub(26wj522ncyprkc0t9yr1e1cz2szempbddkay02qqqxqkjnkbnygg)"},
 		},
@@ -127,10 +174,34 @@ func loadConfig(path string) (*Config, *crypto.Signer, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("couldn't read config: %s", err)
 	}
-	var config *Config
-	if err := json.Unmarshal(bytes, &config); err != nil {
+	// Unmarshal over the defaults, so that policy fields missing from the
+	// file (such as limits added since it was written) keep their defaults.
+	config := &Config{
+		Policy: rules.DefaultPolicy(),
+		DKIM:   dkimproof.DefaultConfig(),
+	}
+	if err := json.Unmarshal(bytes, config); err != nil {
 		return nil, nil, fmt.Errorf("couldn't unmarshal config: %s", err)
 	}
+	if config.Policy == nil {
+		config.Policy = rules.DefaultPolicy()
+	}
+	if config.DNS == nil {
+		config.DNS = &dns.PoolConfig{
+			Resolvers: []*dns.ResolverConfig{{Address: config.DNSServer}},
+		}
+	}
+	if config.DKIM == nil {
+		config.DKIM = dkimproof.DefaultConfig()
+	}
+	if config.MailStore != nil {
+		if config.MailStore.MaxAgeHours == 0 {
+			config.MailStore.MaxAgeHours = defaultMailMaxAgeHours
+		}
+		if config.MailStore.MaxMessages == 0 {
+			config.MailStore.MaxMessages = defaultMailMaxMessages
+		}
+	}
 	signer, err := crypto.NewSigner(config.PrivateKey)
 	if err != nil {
 		return nil, nil, err
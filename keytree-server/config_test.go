@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/rules"
+)
+
+func TestLoadConfigKeepsPolicyDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	// A policy written before some limits existed.
+	_, privateKey := crypto.GenerateRandomEd25519Keypair()
+	contents := `{
+  "PrivateKey": "` + privateKey + `",
+  "Policy": {
+    "MaxKeys": 20,
+    "DKIM": {"MinRSABits": 2048}
+  },
+  "MailStore": {"Dir": "mail"}
+}`
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := rules.DefaultPolicy()
+	if config.Policy.MaxKeys != 20 {
+		t.Errorf("expected MaxKeys from file; got %d", config.Policy.MaxKeys)
+	}
+	if config.Policy.MaxSMIMESignatureValueLength != defaults.MaxSMIMESignatureValueLength ||
+		config.Policy.MaxOIDCSignatureValueLength != defaults.MaxOIDCSignatureValueLength ||
+		config.Policy.MaxSignatures != defaults.MaxSignatures {
+		t.Errorf("expected omitted limits to keep their defaults; got %+v", config.Policy)
+	}
+	if config.Policy.DKIM.MinRSABits != 2048 || len(config.Policy.DKIM.AllowedAlgorithms) == 0 {
+		t.Errorf("expected nested dkim policy to overlay its defaults; got %+v", config.Policy.DKIM)
+	}
+	if config.DKIM == nil || config.DNS == nil {
+		t.Errorf("expected dkim and dns config to be defaulted")
+	}
+	if config.MailStore.MaxAgeHours != defaultMailMaxAgeHours || config.MailStore.MaxMessages != defaultMailMaxMessages {
+		t.Errorf("expected mail store limits to be defaulted; got %+v", config.MailStore)
+	}
+}
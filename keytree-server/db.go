@@ -18,15 +18,34 @@ type DB interface {
 
 	Read(name crypto.Hash) (*wire.SignedEntry, error)
 	ReadSince(name crypto.Hash, timestamp uint64) (*wire.SignedEntry, error)
+	ReadAt(name crypto.Hash, timestamp uint64) (*wire.SignedEntry, error)
 
 	PerformUpdates(updates []*wire.SignedEntry) error
 
+	Audit(record *AuditRecord) error
+	ReadAudit() ([]*AuditRecord, error)
+
+	// DB implements dkimproof.PendingStore.
+	ReadPending(email string) (*wire.DKIMUpdate, error)
+	WritePending(email string, update *wire.DKIMUpdate) error
+	ExpirePending(now uint64) error
+
+	// DB implements mirror.Store.
+	LoadMirror(publicKey string) (*wire.SignedRoot, *trie.Node, error)
+	UpdateMirror(publicKey string, signedRoot *wire.SignedRoot, leaves []*wire.TrieLeaf) error
+
 	Close() error
 }
 
 // Database schema:
 // entries/<entry-hash>/<entry-timestamp> -> JSON wire.SignedEntry
+// audit/<sequence>                       -> JSON AuditRecord
+// dkim-pending/<email>                   -> JSON wire.DKIMUpdate
+// mirrors/<public-key>/root              -> JSON wire.SignedRoot
+// mirrors/<public-key>/leaves/<name-hash> -> entry hash
 // info/schema-version                    -> uint64 schemaVersion
+//
+// Buckets added without a schema version bump are created on first use.
 const schemaVersion = 8
 
 type boltDb struct {
@@ -127,6 +146,32 @@ func (b *boltDb) ReadSince(name crypto.Hash, timestamp uint64) (update *wire.Sig
 	return
 }
 
+// ReadAt returns the latest update for name with a timestamp at or before
+// timestamp.
+func (b *boltDb) ReadAt(name crypto.Hash, timestamp uint64) (update *wire.SignedEntry, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket([]byte("entries"))
+		bucket := entries.Bucket(name.Bytes())
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		var v []byte
+		if k, _ := c.Seek(encoding.EncodeBEUint64(timestamp + 1)); k == nil {
+			_, v = c.Last()
+		} else {
+			_, v = c.Prev()
+		}
+		if v == nil {
+			return nil
+		}
+		update = new(wire.SignedEntry)
+		return json.Unmarshal(v, update)
+	})
+	return
+}
+
 func writeUpdate(tx *bolt.Tx, update *wire.SignedEntry) error {
 	entries := tx.Bucket([]byte("entries"))
 	bucket, err := entries.CreateBucketIfNotExists(crypto.HashString(update.Entry.Name).Bytes())
@@ -156,6 +201,197 @@ func (b *boltDb) PerformUpdates(updates []*wire.SignedEntry) error {
 	})
 }
 
+// dbEntryReader reads current and historical entries from a DB for
+// rules.Verifier.
+type dbEntryReader struct {
+	db DB
+}
+
+func (r *dbEntryReader) ReadEntry(name string) (*wire.Entry, error) {
+	update, err := r.db.Read(crypto.HashString(name))
+	if err != nil || update == nil {
+		return nil, err
+	}
+	return update.Entry, nil
+}
+
+func (r *dbEntryReader) ReadEntryAt(name string, timestamp uint64) (*wire.Entry, error) {
+	update, err := r.db.ReadAt(crypto.HashString(name), timestamp)
+	if err != nil || update == nil {
+		return nil, err
+	}
+	return update.Entry, nil
+}
+
+func (b *boltDb) Audit(record *AuditRecord) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("audit"))
+		if err != nil {
+			return err
+		}
+
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		bytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(encoding.EncodeBEUint64(sequence), bytes)
+	})
+}
+
+func (b *boltDb) ReadAudit() (records []*AuditRecord, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("audit"))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			record := new(AuditRecord)
+			if err := json.Unmarshal(v, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return
+}
+
+func (b *boltDb) ReadPending(email string) (update *wire.DKIMUpdate, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("dkim-pending"))
+		if bucket == nil {
+			return nil
+		}
+
+		v := bucket.Get([]byte(email))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &update)
+	})
+	return
+}
+
+func (b *boltDb) WritePending(email string, update *wire.DKIMUpdate) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("dkim-pending"))
+		if err != nil {
+			return err
+		}
+
+		bytes, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(email), bytes)
+	})
+}
+
+func (b *boltDb) ExpirePending(now uint64) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("dkim-pending"))
+		if bucket == nil {
+			return nil
+		}
+
+		var expired [][]byte
+		if err := bucket.ForEach(func(k, v []byte) error {
+			var update wire.DKIMUpdate
+			if err := json.Unmarshal(v, &update); err != nil {
+				return err
+			}
+			if update.Expiration < now {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltDb) LoadMirror(publicKey string) (signedRoot *wire.SignedRoot, root *trie.Node, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		mirrors := tx.Bucket([]byte("mirrors"))
+		if mirrors == nil {
+			return nil
+		}
+		bucket := mirrors.Bucket([]byte(publicKey))
+		if bucket == nil {
+			return nil
+		}
+
+		if leaves := bucket.Bucket([]byte("leaves")); leaves != nil {
+			if err := leaves.ForEach(func(k, v []byte) error {
+				root = root.Apply(&wire.TrieLeaf{
+					NameHash:  crypto.HashFromBytes(k),
+					EntryHash: crypto.HashFromBytes(v),
+				})
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		root.ParallelHash(runtime.NumCPU()) // force calculation of all hash values
+
+		if v := bucket.Get([]byte("root")); v != nil {
+			return json.Unmarshal(v, &signedRoot)
+		}
+		return nil
+	})
+	return
+}
+
+func (b *boltDb) UpdateMirror(publicKey string, signedRoot *wire.SignedRoot, leaves []*wire.TrieLeaf) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		mirrors, err := tx.CreateBucketIfNotExists([]byte("mirrors"))
+		if err != nil {
+			return err
+		}
+		bucket, err := mirrors.CreateBucketIfNotExists([]byte(publicKey))
+		if err != nil {
+			return err
+		}
+		leavesBucket, err := bucket.CreateBucketIfNotExists([]byte("leaves"))
+		if err != nil {
+			return err
+		}
+
+		for _, leaf := range leaves {
+			if leaf.IsTombstone() {
+				err = leavesBucket.Delete(leaf.NameHash.Bytes())
+			} else {
+				err = leavesBucket.Put(leaf.NameHash.Bytes(), leaf.EntryHash.Bytes())
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if signedRoot == nil {
+			return bucket.Delete([]byte("root"))
+		}
+		bytes, err := json.Marshal(signedRoot)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("root"), bytes)
+	})
+}
+
 func (b *boltDb) Load() (root *trie.Node, err error) {
 	err = b.db.View(func(tx *bolt.Tx) error {
 		root = nil
@@ -170,8 +406,7 @@ func (b *boltDb) Load() (root *trie.Node, err error) {
 				return err
 			}
 
-			leaf := update.Entry.ToLeaf()
-			root = root.Set(leaf.NameHash, leaf)
+			root = root.Apply(update.Entry.ToLeaf())
 		}
 		root.ParallelHash(runtime.NumCPU()) // force calculation of all hash values
 
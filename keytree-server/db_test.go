@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/trie"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func openTestDB(t *testing.T) (DB, func()) {
+	dir, err := ioutil.TempDir("", "keytree-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB(filepath.Join(dir, "keytree.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReadAt(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	var updates []*wire.SignedEntry
+	for _, timestamp := range []uint64{100, 200} {
+		updates = append(updates, &wire.SignedEntry{
+			Entry: &wire.Entry{
+				Name:      "domain:example.com",
+				Keys:      map[string]string{},
+				Timestamp: timestamp,
+			},
+			Signatures: map[string]string{},
+		})
+	}
+	if err := db.PerformUpdates(updates); err != nil {
+		t.Fatal(err)
+	}
+
+	name := crypto.HashString("domain:example.com")
+	for _, c := range []struct {
+		at       uint64
+		expected uint64
+	}{
+		{50, 0},
+		{100, 100},
+		{150, 100},
+		{200, 200},
+		{300, 200},
+	} {
+		update, err := db.ReadAt(name, c.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var timestamp uint64
+		if update != nil {
+			timestamp = update.Entry.Timestamp
+		}
+		if timestamp != c.expected {
+			t.Errorf("ReadAt(%d) returned timestamp %d, expected %d", c.at, timestamp, c.expected)
+		}
+	}
+}
+
+func TestMirrorRoundTrip(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if signedRoot, root, err := db.LoadMirror("peer"); err != nil || signedRoot != nil || root != nil {
+		t.Fatalf("expected nothing stored; got %v, %v, %v", signedRoot, root, err)
+	}
+
+	a := &wire.TrieLeaf{NameHash: crypto.HashString("a"), EntryHash: crypto.HashString("a1")}
+	b := &wire.TrieLeaf{NameHash: crypto.HashString("b"), EntryHash: crypto.HashString("b1")}
+	expected := (*trie.Node)(nil).Apply(a).Apply(b)
+	signedRoot := &wire.SignedRoot{
+		Root:      &wire.Root{RootHash: expected.Hash(), Timestamp: 1},
+		Signature: "signature",
+	}
+	if err := db.UpdateMirror("peer", signedRoot, []*wire.TrieLeaf{a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedRoot, root, err := db.LoadMirror("peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Hash() != expected.Hash() || loadedRoot == nil || loadedRoot.Root.RootHash != expected.Hash() {
+		t.Errorf("loaded trie does not match stored trie")
+	}
+
+	// A tombstone removes a leaf; a nil root marks the trie as partial.
+	if err := db.UpdateMirror("peer", nil, []*wire.TrieLeaf{{NameHash: a.NameHash, EntryHash: crypto.EmptyHash}}); err != nil {
+		t.Fatal(err)
+	}
+	loadedRoot, root, err = db.LoadMirror("peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedRoot != nil || root.Hash() != (*trie.Node)(nil).Apply(b).Hash() {
+		t.Errorf("expected partial trie with only b; got %v, %v", loadedRoot, root.Hash())
+	}
+
+	if signedRoot, root, err := db.LoadMirror("other"); err != nil || signedRoot != nil || root != nil {
+		t.Errorf("expected nothing stored for other peer; got %v, %v, %v", signedRoot, root, err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/jellevandenhooff/keytree/rules"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+const hookQueueSize = 1000
+const hookTimeout = 30 * time.Second
+
+// A HookConfig describes how to notify someone of an event. Webhooks receive
+// the event as a JSON POST body; commands receive it on stdin.
+type HookConfig struct {
+	WebhookURL string   `json:",omitempty"`
+	Command    []string `json:",omitempty"`
+}
+
+// A RecoveryEvent is sent when a record enters recovery. After Deadline, the
+// record can be changed with just a proof of ownership.
+type RecoveryEvent struct {
+	Server    string
+	Name      string
+	Timestamp uint64
+	Deadline  uint64
+}
+
+// A recoveryWatcher fires hooks for records entering recovery. Hooks run in
+// the background so they never hold up update processing.
+type recoveryWatcher struct {
+	server     string
+	hooks      []HookConfig
+	events     chan *RecoveryEvent
+	httpClient *http.Client
+}
+
+func newRecoveryWatcher(server string, hooks []HookConfig) *recoveryWatcher {
+	return &recoveryWatcher{
+		server: server,
+		hooks:  hooks,
+		events: make(chan *RecoveryEvent, hookQueueSize),
+		httpClient: &http.Client{
+			Timeout: hookTimeout,
+		},
+	}
+}
+
+// enteredRecovery returns whether entry puts old into recovery.
+func enteredRecovery(old, entry *wire.Entry) bool {
+	return entry.InRecovery && (old == nil || !old.InRecovery)
+}
+
+func (w *recoveryWatcher) notify(entry *wire.Entry) {
+	if len(w.hooks) == 0 {
+		return
+	}
+
+	event := &RecoveryEvent{
+		Server:    w.server,
+		Name:      entry.Name,
+		Timestamp: entry.Timestamp,
+		Deadline:  entry.Timestamp + rules.RecoverWaitTime,
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		log.Printf("dropping recovery notification for %s; queue full\n", entry.Name)
+	}
+}
+
+func (w *recoveryWatcher) fire(hook HookConfig, event *RecoveryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if hook.WebhookURL != "" {
+		resp, err := w.httpClient.Post(hook.WebhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook %s returned %s", hook.WebhookURL, resp.Status)
+		}
+	}
+
+	if len(hook.Command) > 0 {
+		cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(),
+			"KEYTREE_EVENT=recovery",
+			"KEYTREE_NAME="+event.Name,
+			fmt.Sprintf("KEYTREE_DEADLINE=%d", event.Deadline))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("command %v failed: %s (%s)", hook.Command, err, output)
+		}
+	}
+
+	return nil
+}
+
+func (w *recoveryWatcher) run() {
+	for event := range w.events {
+		for _, hook := range w.hooks {
+			if err := w.fire(hook, event); err != nil {
+				log.Printf("recovery hook for %s failed: %s\n", event.Name, err)
+			}
+		}
+	}
+}
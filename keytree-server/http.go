@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/rules"
 	"github.com/jellevandenhooff/keytree/trie"
 	"github.com/jellevandenhooff/keytree/wire"
 )
@@ -99,7 +100,7 @@ func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var entry *wire.Entry
-	if update != nil {
+	if update != nil && !update.Entry.Deleted {
 		entry = update.Entry
 	}
 
@@ -219,6 +220,41 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	wire.ReplyJSON(w, err)
 }
 
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var update *wire.SignedEntry
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := update.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := rules.CheckUpdate(update, s.config.Policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Updates still pending in processUpdates are not visible here, so the
+	// decision might be stale for records that are being updated right now.
+	var oldEntry *wire.Entry
+	oldUpdate, err := s.db.Read(update.Entry.ToLeaf().NameHash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if oldUpdate != nil {
+		oldEntry = oldUpdate.Entry
+	}
+
+	decision, _ := s.verifier.ExplainUpdate(oldEntry, update, updateWindow())
+	wire.ReplyJSON(w, decision)
+}
+
 func (s *Server) handleUpdateBatch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
@@ -246,6 +282,21 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	wire.ReplyJSON(w, s.localTrie.signedRoot)
 }
 
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	wire.ReplyJSON(w, &Status{
+		PublicKey:    s.config.PublicKey,
+		Upstream:     s.config.Upstream,
+		TotalNodes:   s.dedup.NumNodes(),
+		PolicyDigest: s.verifier.Digest(),
+		DKIMDomain:   s.config.DKIM.Domain,
+		DNSCache:     s.dnsClient.Stats(),
+		DNSResolvers: s.dnsPool.Health(),
+		Fetch:        s.coordinator.Stats(),
+	})
+}
+
 func (s *Server) addHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/keytree/lookup", func(w http.ResponseWriter, r *http.Request) {
 		s.handleLookup(w, r)
@@ -274,4 +325,24 @@ func (s *Server) addHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/keytree/submit", func(w http.ResponseWriter, r *http.Request) {
 		s.handleSubmit(w, r)
 	})
+
+	mux.HandleFunc("/keytree/check", func(w http.ResponseWriter, r *http.Request) {
+		s.handleCheck(w, r)
+	})
+
+	mux.HandleFunc("/keytree/admin/delete", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAdminDelete(w, r)
+	})
+
+	mux.HandleFunc("/keytree/admin/audit", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAdminAudit(w, r)
+	})
+
+	mux.HandleFunc("/keytree/admin/mail", func(w http.ResponseWriter, r *http.Request) {
+		s.handleAdminMail(w, r)
+	})
+
+	mux.HandleFunc("/keytree/status", func(w http.ResponseWriter, r *http.Request) {
+		s.handleStatus(w, r)
+	})
 }
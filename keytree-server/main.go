@@ -11,10 +11,14 @@ import (
 	"time"
 
 	"github.com/jellevandenhooff/keytree/concurrency"
+	"github.com/jellevandenhooff/keytree/crypto"
 	"github.com/jellevandenhooff/keytree/dkimproof"
 	"github.com/jellevandenhooff/keytree/dns"
+	"github.com/jellevandenhooff/keytree/domainproof"
+	"github.com/jellevandenhooff/keytree/httpsproof"
 	"github.com/jellevandenhooff/keytree/mirror"
 	"github.com/jellevandenhooff/keytree/rules"
+	"github.com/jellevandenhooff/keytree/smimeproof"
 	"github.com/jellevandenhooff/keytree/trie"
 	"github.com/jellevandenhooff/keytree/webdata"
 
@@ -22,9 +26,14 @@ import (
 )
 
 type Status struct {
-	PublicKey  string
-	Upstream   []ServerInfo
-	TotalNodes int
+	PublicKey    string
+	Upstream     []ServerInfo
+	TotalNodes   int
+	PolicyDigest crypto.Hash
+	DKIMDomain   string // domain of DKIM verification addresses
+	DNSCache     dns.CacheStats
+	DNSResolvers []dns.ResolverHealth
+	Fetch        mirror.FetchStats
 }
 
 type CloserReader struct {
@@ -68,7 +77,10 @@ func main() {
 	}
 
 	dedup := trie.NewDedup()
-	coordinator := mirror.NewCoordinator(dedup)
+	coordinator := mirror.NewCoordinator(dedup, config.FetchParallelism)
+	for _, serverInfo := range config.Upstream {
+		coordinator.SetWeight(serverInfo.Address, serverInfo.FetchWeight)
+	}
 
 	reconcileLocks := concurrency.NewHashLocker()
 
@@ -78,7 +90,45 @@ func main() {
 	trackers := make(map[string]*tracker)
 	allTries := make(map[string]*lookupTrie)
 
-	dnsClient := dns.NewCachingDNSClient(config.DNSServer)
+	dnsPool, err := dns.NewPool(config.DNS)
+	if err != nil {
+		log.Fatalf("couldn't set up dns: %s\n", err)
+	}
+	var resolver dns.TxtResolver = dnsPool
+	if config.DNSSEC != nil {
+		if resolver, err = dns.NewValidator(dnsPool, config.DNSSEC); err != nil {
+			log.Fatalf("couldn't set up dnssec: %s\n", err)
+		}
+	}
+	dnsClient := dns.NewCachingResolver(resolver, dns.DefaultMinTTL, dns.DefaultMaxTTL)
+
+	// Trust https, domain and dkim key attestations from ourselves and from our
+	// upstream servers, since we replay their history.
+	attesters := []string{config.PublicKey}
+	for _, serverInfo := range config.Upstream {
+		attesters = append(attesters, serverInfo.PublicKey)
+	}
+
+	verifier := rules.NewVerifier(dnsClient, config.Policy, attesters, &dbEntryReader{db: db})
+	// When catching up, old DKIM proofs may be signed by keys since rotated.
+	verifier.SetArchivedDKIMKeys(catchUpRecoveryEnabled)
+	if config.DKIM.SMIMETrustStoreFile != "" {
+		smimeTrust, err := smimeproof.LoadTrustStore(config.DKIM.SMIMETrustStoreFile)
+		if err != nil {
+			log.Fatalf("couldn't load s/mime trust store: %s\n", err)
+		}
+		verifier.SetSMIMETrustStore(smimeTrust)
+	}
+	verifier.SetOIDCIssuers(config.OIDCIssuers)
+
+	var mailStore *dkimproof.MailStore
+	if config.MailStore != nil {
+		mailStore, err = dkimproof.NewMailStore(filepath.Join(*dataDir, config.MailStore.Dir),
+			time.Duration(config.MailStore.MaxAgeHours)*time.Hour, config.MailStore.MaxMessages)
+		if err != nil {
+			log.Fatalf("couldn't create mail store: %s\n", err)
+		}
+	}
 
 	s := &Server{
 		config: config,
@@ -92,29 +142,41 @@ func main() {
 		db:        db,
 		localTrie: nil,
 
-		updateCache:    updateCache,
-		trieCache:      trieCache,
-		updateRequests: make(chan updateRequest, updateQueueSize),
+		updateCache:     updateCache,
+		trieCache:       trieCache,
+		updateRequests:  make(chan updateRequest, updateQueueSize),
+		recoveryWatcher: newRecoveryWatcher(config.PublicKey, config.RecoveryHooks),
+		mailStore:       mailStore,
+		dnsClient:       dnsClient,
+		dnsPool:         dnsPool,
 
 		trackers: trackers,
 		allTries: allTries,
 
-		verifier: rules.NewVerifier(dnsClient),
+		verifier: verifier,
 	}
 	s.setAndSignRoot(root)
 
+	go s.recoveryWatcher.run()
 	go s.processUpdates()
 	go s.follow(context.Background())
 
-	dkimServer, err := dkimproof.RunServer("keytree.io", dnsClient)
+	dkimServer, err := dkimproof.RunServer(config.DKIM, config.Policy.DKIM, dnsClient, db, mailStore, config.PublicKey, signer)
 	if err != nil {
 		log.Printf("could not start DKIM server: %s", err)
 	}
 
+	httpsServer := httpsproof.NewServer(config.PublicKey, signer)
+	domainServer := domainproof.NewServer(config.PublicKey, signer, dnsClient)
+
 	mux := http.NewServeMux()
 
 	s.addHandlers(mux)
-	dkimServer.AddHandlers(mux)
+	if dkimServer != nil {
+		dkimServer.AddHandlers(mux)
+	}
+	httpsServer.AddHandlers(mux)
+	domainServer.AddHandlers(mux)
 	mux.Handle("/", webdata.FileServer())
 
 	server := &http.Server{
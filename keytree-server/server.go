@@ -8,6 +8,8 @@ import (
 
 	"github.com/jellevandenhooff/keytree/concurrency"
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/dkimproof"
+	"github.com/jellevandenhooff/keytree/dns"
 	"github.com/jellevandenhooff/keytree/mirror"
 	"github.com/jellevandenhooff/keytree/rules"
 	"github.com/jellevandenhooff/keytree/trie"
@@ -25,6 +27,9 @@ type lookupTrie struct {
 type updateRequest struct {
 	update *wire.SignedEntry
 	result chan error
+
+	// admin updates are tombstones that skip ownership verification
+	admin bool
 }
 
 type Server struct {
@@ -39,9 +44,13 @@ type Server struct {
 	db          DB                  // stores all data for the current local trie, thread-safe
 
 	// updates and distribution
-	updateCache    *updateCache       // provides channels with updates
-	trieCache      *trieCache         // local recent trie tracker
-	updateRequests chan updateRequest // channel to the update thread
+	updateCache     *updateCache         // provides channels with updates
+	trieCache       *trieCache           // local recent trie tracker
+	updateRequests  chan updateRequest   // channel to the update thread
+	recoveryWatcher *recoveryWatcher     // notifies of records entering recovery
+	mailStore       *dkimproof.MailStore // received DKIM emails; may be nil
+	dnsClient       *dns.CachingDNSClient
+	dnsPool         *dns.Pool
 
 	reconcileLocks *concurrency.HashLocker
 
@@ -57,6 +66,12 @@ func (s *Server) doUpdate(update *wire.SignedEntry) error {
 	return <-c
 }
 
+func (s *Server) doAdminUpdate(update *wire.SignedEntry) error {
+	c := make(chan error, 1)
+	s.updateRequests <- updateRequest{update: update, result: c, admin: true}
+	return <-c
+}
+
 func (s *Server) setAndSignRoot(newRoot *trie.Node) {
 	// s must be locked
 
@@ -78,10 +93,26 @@ func (s *Server) setAndSignRoot(newRoot *trie.Node) {
 	s.allTries[s.config.PublicKey] = s.localTrie
 }
 
+// updateWindow returns the window of acceptable update timestamps.
+func updateWindow() rules.Window {
+	now := unixtime.Now()
+	if catchUpRecoveryEnabled {
+		return rules.Window{
+			Start: catchUpRecoveryCutoff - 15*60,
+			End:   now + 15*60,
+		}
+	}
+	return rules.Window{
+		Start: now - 15*60,
+		End:   now + 15*60,
+	}
+}
+
 func (s *Server) processUpdates() {
 	newRoot := s.localTrie.root
 	pendingUpdates := make([]*wire.SignedEntry, 0)
 	pending := make(map[crypto.Hash]*wire.Entry)
+	var enteringRecovery []*wire.Entry
 
 	flushTimer := time.After(noFlushUpdateInterval)
 
@@ -90,7 +121,7 @@ func (s *Server) processUpdates() {
 		case req := <-s.updateRequests:
 			update := req.update
 
-			if err := rules.CheckUpdate(update); err != nil {
+			if err := rules.CheckUpdate(update, s.config.Policy); err != nil {
 				req.result <- err
 				break
 			}
@@ -111,21 +142,12 @@ func (s *Server) processUpdates() {
 				}
 			}
 
-			var window rules.Window
-			now := unixtime.Now()
-			if catchUpRecoveryEnabled {
-				window = rules.Window{
-					Start: catchUpRecoveryCutoff - 15*60,
-					End:   now + 15*60,
-				}
-			} else {
-				window = rules.Window{
-					Start: now - 15*60,
-					End:   now + 15*60,
+			if req.admin {
+				if err := checkAdminDelete(oldEntry, update); err != nil {
+					req.result <- err
+					break
 				}
-			}
-
-			if err := s.verifier.VerifyUpdate(oldEntry, update, window); err != nil {
+			} else if err := s.verifier.VerifyUpdate(oldEntry, update, updateWindow()); err != nil {
 				req.result <- err
 				break
 			}
@@ -134,7 +156,11 @@ func (s *Server) processUpdates() {
 				flushTimer = time.After(updateFlushInterval)
 			}
 
-			newRoot = newRoot.Set(leaf.NameHash, leaf)
+			if enteredRecovery(oldEntry, update.Entry) {
+				enteringRecovery = append(enteringRecovery, update.Entry)
+			}
+
+			newRoot = newRoot.Apply(leaf)
 			pendingUpdates = append(pendingUpdates, update)
 			pending[leaf.NameHash] = update.Entry
 			req.result <- nil
@@ -145,6 +171,11 @@ func (s *Server) processUpdates() {
 				log.Printf("flushing failed: %s\n", err)
 				newRoot = s.localTrie.root
 				pendingUpdates = nil
+				enteringRecovery = nil
+			}
+
+			for _, entry := range enteringRecovery {
+				s.recoveryWatcher.notify(entry)
 			}
 
 			leaves := make([]*wire.TrieLeaf, len(pendingUpdates))
@@ -164,6 +195,7 @@ func (s *Server) processUpdates() {
 			newRoot = s.localTrie.root
 			pendingUpdates = nil
 			pending = make(map[crypto.Hash]*wire.Entry)
+			enteringRecovery = nil
 
 			flushTimer = time.After(noFlushUpdateInterval)
 		}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"sync"
 
 	"github.com/jellevandenhooff/keytree/crypto"
 	"github.com/jellevandenhooff/keytree/mirror"
@@ -20,7 +21,38 @@ type tracker struct {
 	address, publicKey string
 
 	// resolve queue
-	queue chan crypto.Hash
+	queue chan *fixupRequest
+
+	mu sync.Mutex
+	// Differences that reconcile need not queue again, by name.
+	resolved map[crypto.Hash]fixupRequest
+}
+
+// A fixupRequest asks the fixer to fetch the history of a name.
+type fixupRequest struct {
+	name crypto.Hash
+
+	// For requests from reconcile, the differing local and remote entry
+	// hashes, zero if missing. Once fixed up, upstream has nothing that
+	// resolves the difference, so later passes skip it until either changes.
+	fromReconcile bool
+	local, remote crypto.Hash
+}
+
+func (t *tracker) isResolved(f *fixupRequest) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resolved, found := t.resolved[f.name]
+	return found && resolved == *f
+}
+
+func (t *tracker) markResolved(f *fixupRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resolved == nil {
+		t.resolved = make(map[crypto.Hash]fixupRequest)
+	}
+	t.resolved[f.name] = *f
 }
 
 func (t *tracker) FullSync(s *wire.SignedRoot, n *trie.Node) {
@@ -32,7 +64,7 @@ func (t *tracker) FullSync(s *wire.SignedRoot, n *trie.Node) {
 
 	// todo: only reconcile updated nodes? some clever trie-based thing could be used for all three cases!
 
-	_ = t.reconcile(localRoot, n, 0)
+	_ = t.reconcile(localRoot, n, 0, true)
 }
 
 func (t *tracker) PartialSync(s *wire.SignedRoot, n *trie.Node) {
@@ -42,29 +74,32 @@ func (t *tracker) PartialSync(s *wire.SignedRoot, n *trie.Node) {
 	localRoot := t.server.localTrie.root
 	t.server.mu.Unlock()
 
-	_ = t.reconcile(localRoot, n, 0)
+	// A partial trie misses subtrees that were not fetched yet, so names
+	// missing from it need not have been deleted.
+	_ = t.reconcile(localRoot, n, 0, false)
 }
 
 func (t *tracker) Updated(s *wire.SignedRoot, n *trie.Node, u []*wire.TrieLeaf) {
 	t.server.considerTrie(t.publicKey, n, s)
 
 	for _, leaf := range u {
-		t.queue <- leaf.NameHash
+		t.queue <- &fixupRequest{name: leaf.NameHash}
 	}
 }
 
 func (t *tracker) fixer() error {
 	for t.ctx.Err() == nil {
 		select {
-		case h := <-t.queue:
-			t.fixup(h)
+		case f := <-t.queue:
+			t.fixup(f)
 		case <-t.ctx.Done():
 		}
 	}
 	return t.ctx.Err()
 }
 
-func (t *tracker) fixup(h crypto.Hash) {
+func (t *tracker) fixup(f *fixupRequest) {
+	h := f.name
 	t.server.reconcileLocks.Lock(h)
 	defer t.server.reconcileLocks.Unlock(h)
 
@@ -95,6 +130,10 @@ func (t *tracker) fixup(h crypto.Hash) {
 		}
 		since = update.Entry.Timestamp + 1
 	}
+
+	if f.fromReconcile {
+		t.markResolved(f)
+	}
 }
 
 func areSameEntry(local, remote *trie.Node) bool {
@@ -111,25 +150,40 @@ func areSameEntry(local, remote *trie.Node) bool {
 		local.Entry.NameHash == remote.Entry.NameHash
 }
 
-func (t *tracker) reconcile(local, remote *trie.Node, depth int) error {
+// reconcile queues the names that differ between local and remote for the
+// fixer. If complete is set, remote is a complete trie, and names missing
+// from it are queued too, so that the fixer fetches their tombstones. Names
+// that were fixed up before and still differ in the same way are skipped, so
+// that names upstream rejected or never had are not fetched on every pass.
+func (t *tracker) reconcile(local, remote *trie.Node, depth int, complete bool) error {
 	if err := t.ctx.Err(); err != nil {
 		return err
 	}
 
-	if remote == nil || local.Hash() == remote.Hash() {
+	if local.Hash() == remote.Hash() || (remote == nil && !complete) {
 		return nil
 	}
 
 	if areSameEntry(local, remote) {
+		f := &fixupRequest{fromReconcile: true}
+		if local != nil {
+			f.name, f.local = local.Entry.NameHash, local.Entry.EntryHash
+		}
+		if remote != nil {
+			f.name, f.remote = remote.Entry.NameHash, remote.Entry.EntryHash
+		}
+		if t.isResolved(f) {
+			return nil
+		}
 		select {
-		case t.queue <- remote.Entry.NameHash:
+		case t.queue <- f:
 		case <-t.ctx.Done():
 		}
 	} else {
 		localChildren := local.Split(depth)
 		remoteChildren := remote.Split(depth)
 		for i := 0; i < 2; i++ {
-			if err := t.reconcile(localChildren[i], remoteChildren[i], depth+1); err != nil {
+			if err := t.reconcile(localChildren[i], remoteChildren[i], depth+1, complete); err != nil {
 				return err
 			}
 		}
@@ -137,6 +191,18 @@ func (t *tracker) reconcile(local, remote *trie.Node, depth int) error {
 	return nil
 }
 
+func (t *tracker) checkPolicy() {
+	digest, err := t.conn.PolicyDigest()
+	if err != nil {
+		log.Printf("could not fetch policy for %s: %s", t.address, err)
+		return
+	}
+
+	if digest != t.server.verifier.Digest() {
+		log.Printf("policy of %s differs from local policy; updates might be rejected", t.address)
+	}
+}
+
 func runTracker(ctx context.Context, s *Server, address string, publicKey string) *tracker {
 	log.Printf("spawning tracker for %s at %s", publicKey, address)
 
@@ -148,14 +214,15 @@ func runTracker(ctx context.Context, s *Server, address string, publicKey string
 		server:    s,
 		address:   address,
 		publicKey: publicKey,
-		queue:     make(chan crypto.Hash, reconcileQueueSize),
+		queue:     make(chan *fixupRequest, reconcileQueueSize),
 	}
 
-	t.mirror = mirror.NewMirror(ctx, s.coordinator, conn, address, publicKey, nil, t)
+	t.mirror = mirror.NewMirror(ctx, s.coordinator, conn, address, publicKey, s.db, t)
 
 	for i := 0; i < fixerParallelism; i++ {
 		go t.fixer()
 	}
+	go t.checkPolicy()
 	go t.mirror.Run()
 
 	return t
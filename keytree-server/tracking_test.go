@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/trie"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func TestReconcileQueuesDeletedNames(t *testing.T) {
+	var local *trie.Node
+	for _, name := range []string{"a", "b", "c", "d"} {
+		local = local.Apply(&wire.TrieLeaf{
+			NameHash:  crypto.HashString(name),
+			EntryHash: crypto.HashString("v1"),
+		})
+	}
+	// Upstream deleted c.
+	remote := local.Set(crypto.HashString("c"), nil)
+
+	tr := &tracker{
+		ctx:   context.Background(),
+		queue: make(chan *fixupRequest, 10),
+	}
+
+	if err := tr.reconcile(local, remote, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 0 {
+		t.Errorf("expected partial trie not to queue missing names; got %d", len(tr.queue))
+	}
+
+	if err := tr.reconcile(local, remote, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 1 || (<-tr.queue).name != crypto.HashString("c") {
+		t.Errorf("expected deleted name to be queued")
+	}
+
+	// An empty complete trie means everything was deleted.
+	if err := tr.reconcile(local, nil, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 4 {
+		t.Errorf("expected all names to be queued; got %d", len(tr.queue))
+	}
+}
+
+func TestReconcileSkipsResolvedNames(t *testing.T) {
+	var local *trie.Node
+	for _, name := range []string{"a", "b", "c"} {
+		local = local.Apply(&wire.TrieLeaf{
+			NameHash:  crypto.HashString(name),
+			EntryHash: crypto.HashString("v1"),
+		})
+	}
+	// Upstream never accepted c.
+	remote := local.Set(crypto.HashString("c"), nil)
+
+	tr := &tracker{
+		ctx:   context.Background(),
+		queue: make(chan *fixupRequest, 10),
+	}
+
+	if err := tr.reconcile(local, remote, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 1 {
+		t.Fatalf("expected local-only name to be queued; got %d", len(tr.queue))
+	}
+	// Fetching its history from upstream found nothing.
+	tr.markResolved(<-tr.queue)
+
+	if err := tr.reconcile(local, remote, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 0 {
+		t.Errorf("expected resolved name not to be queued again; got %d", len(tr.queue))
+	}
+
+	// A new local entry is a new difference.
+	local = local.Apply(&wire.TrieLeaf{
+		NameHash:  crypto.HashString("c"),
+		EntryHash: crypto.HashString("v2"),
+	})
+	if err := tr.reconcile(local, remote, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.queue) != 1 {
+		t.Errorf("expected changed name to be queued; got %d", len(tr.queue))
+	}
+}
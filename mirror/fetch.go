@@ -14,7 +14,8 @@ import (
 
 type fetcher struct {
 	ctx   context.Context
-	p     *concurrency.PrioritySemaphore
+	peer  string
+	s     *concurrency.FairScheduler
 	h     *concurrency.HashLocker
 	dedup *trie.Dedup
 	conn  *wire.KeyTreeClient
@@ -41,27 +42,29 @@ func (f *fetcher) fetch(hash crypto.Hash, depth int, old *trie.Node, batched *wi
 		return nil, nil
 	}
 
-	concurrency.LockBoth(f.p.LockFor(depth), f.h.LockFor(hash))
+	if err := concurrency.LockBothContext(f.ctx, f.s.LockFor(f.peer, depth), f.h.LockFor(hash)); err != nil {
+		return f.dedup.Add(old), err
+	}
 	defer f.h.Unlock(hash)
 
 	if err := f.ctx.Err(); err != nil {
-		f.p.Release()
+		f.s.Release(f.peer)
 		return f.dedup.Add(old), err
 	}
 
 	if node := f.dedup.FindAndAdd(hash); node != nil {
-		f.p.Release()
+		f.s.Release(f.peer)
 		return node, nil
 	}
 
 	var node *wire.TrieNode
 	if batched != nil {
 		node = batched
-		f.p.Release()
+		f.s.Release(f.peer)
 	} else {
 		var err error
 		node, err = f.conn.TrieNode(hash, 4)
-		f.p.Release()
+		f.s.Release(f.peer)
 		if err != nil {
 			return f.dedup.Add(old), err
 		}
@@ -108,27 +111,59 @@ func (f *fetcher) fetch(hash crypto.Hash, depth int, old *trie.Node, batched *wi
 	return f.dedup.AddWithChildrenAlreadyAdded(trie.Merge(children)), err
 }
 
+// DefaultFetchParallelism is the default number of trie node requests a
+// Coordinator has outstanding at once, over all peers.
+const DefaultFetchParallelism = 16
+
 type Coordinator struct {
 	// read-only
 	dedup *trie.Dedup
 	h     *concurrency.HashLocker
+	s     *concurrency.FairScheduler
 }
 
-func NewCoordinator(dedup *trie.Dedup) *Coordinator {
+// NewCoordinator returns a Coordinator making at most parallelism requests at
+// once; if 0, DefaultFetchParallelism.
+func NewCoordinator(dedup *trie.Dedup, parallelism int) *Coordinator {
+	if parallelism == 0 {
+		parallelism = DefaultFetchParallelism
+	}
+
 	return &Coordinator{
 		dedup: dedup,
 		h:     concurrency.NewHashLocker(),
+		s:     concurrency.NewFairScheduler(parallelism),
 	}
 }
 
-func (c *Coordinator) Fetch(ctx context.Context, conn *wire.KeyTreeClient, parallelism int, hash crypto.Hash, old *trie.Node) (*trie.Node, error) {
+// SetWeight sets peer's share of requests when several peers are fetching.
+func (c *Coordinator) SetWeight(peer string, weight int) {
+	c.s.SetWeight(peer, weight)
+}
+
+func (c *Coordinator) Fetch(ctx context.Context, conn *wire.KeyTreeClient, peer string, hash crypto.Hash, old *trie.Node) (*trie.Node, error) {
 	fetcher := &fetcher{
 		ctx:   ctx,
+		peer:  peer,
 		conn:  conn,
 		dedup: c.dedup,
-		p:     concurrency.NewPrioritySemaphore(parallelism),
+		s:     c.s,
 		h:     c.h,
 	}
 
 	return fetcher.fetch(hash, 0, old, nil)
 }
+
+type FetchStats struct {
+	// Waits for nodes being fetched by another mirror.
+	Nodes concurrency.WaitStats
+	// Request slots held and waited for, by peer.
+	Peers map[string]concurrency.ClassStats
+}
+
+func (c *Coordinator) Stats() FetchStats {
+	return FetchStats{
+		Nodes: c.h.Stats(),
+		Peers: c.s.Stats(),
+	}
+}
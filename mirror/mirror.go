@@ -11,8 +11,6 @@ import (
 	"golang.org/x/net/context"
 )
 
-const fetchParallelism = 8
-
 type TrieFollower interface {
 	FullSync(*wire.SignedRoot, *trie.Node)
 	PartialSync(*wire.SignedRoot, *trie.Node)
@@ -27,11 +25,24 @@ type Mirror struct {
 
 	coordinator *Coordinator
 
-	root *trie.Node
+	root       *trie.Node
+	signedRoot *wire.SignedRoot // matching root, or nil if root is partial
 
+	store    Store // may be nil
 	follower TrieFollower
 }
 
+// save persists leaves and signedRoot. Failures are only logged; at worst, a
+// restarted mirror fetches more than it needs to.
+func (m *Mirror) save(signedRoot *wire.SignedRoot, leaves []*wire.TrieLeaf) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.UpdateMirror(m.publicKey, signedRoot, leaves); err != nil {
+		log.Printf("could not store trie for %s: %s\n", m.address, err)
+	}
+}
+
 func (m *Mirror) track() error {
 	for m.ctx.Err() == nil {
 		root := m.root
@@ -47,7 +58,7 @@ func (m *Mirror) track() error {
 
 		newRoot := root
 		for _, leaf := range batch.Updates {
-			newRoot = newRoot.Set(leaf.NameHash, leaf)
+			newRoot = newRoot.Apply(leaf)
 		}
 
 		if newRoot.Hash() != batch.NewRoot.Root.RootHash {
@@ -57,6 +68,8 @@ func (m *Mirror) track() error {
 		newRoot = m.coordinator.dedup.Add(newRoot)
 		m.coordinator.dedup.Remove(m.root)
 		m.root = newRoot
+		m.signedRoot = batch.NewRoot
+		m.save(batch.NewRoot, batch.Updates)
 
 		m.follower.Updated(batch.NewRoot, newRoot, batch.Updates)
 	}
@@ -84,16 +97,21 @@ func (m *Mirror) fetch() error {
 	defer cancel()
 
 	oldRoot := m.root
-	root, err := m.coordinator.Fetch(newCtx, m.conn, fetchParallelism, rootHash, oldRoot)
+	root, err := m.coordinator.Fetch(newCtx, m.conn, m.address, rootHash, oldRoot)
 
 	// Store root even if fetch did not succeed.
+	leaves := trie.Diff(oldRoot, root)
 	m.coordinator.dedup.Remove(m.root)
 	m.root = root
 
 	// Keep signature iff hash matches signature.
 	if root.Hash() == rootHash {
+		m.signedRoot = signedRoot
+		m.save(signedRoot, leaves)
 		m.follower.FullSync(signedRoot, root)
 	} else {
+		m.signedRoot = nil
+		m.save(nil, leaves)
 		m.follower.PartialSync(signedRoot, root)
 	}
 
@@ -101,6 +119,13 @@ func (m *Mirror) fetch() error {
 }
 
 func (m *Mirror) Run() error {
+	// Serve a resumed trie right away; tracking catches it up from there.
+	// It may be stale, so it is only a partial sync: names missing from it
+	// need not have been deleted upstream.
+	if m.signedRoot != nil {
+		m.follower.PartialSync(m.signedRoot, m.root)
+	}
+
 	for m.ctx.Err() == nil {
 		err := m.track()
 		if err == wire.ErrNotFound {
@@ -129,8 +154,25 @@ func (m *Mirror) Run() error {
 	return m.ctx.Err()
 }
 
-func NewMirror(ctx context.Context, coordinator *Coordinator, conn *wire.KeyTreeClient, address, publicKey string, initial *trie.Node, follower TrieFollower) *Mirror {
-	// Add refs?
+// NewMirror returns a Mirror of the server at address. If store holds a trie
+// from an earlier run, the Mirror resumes from it: it first tries to track
+// from the stored root, and otherwise only fetches nodes that changed since.
+func NewMirror(ctx context.Context, coordinator *Coordinator, conn *wire.KeyTreeClient, address, publicKey string, store Store, follower TrieFollower) *Mirror {
+	var signedRoot *wire.SignedRoot
+	var root *trie.Node
+	if store != nil {
+		var err error
+		if signedRoot, root, err = store.LoadMirror(publicKey); err != nil {
+			log.Printf("could not load stored trie for %s: %s\n", address, err)
+			signedRoot, root = nil, nil
+		}
+	}
+	if signedRoot != nil {
+		if err := crypto.Verify(publicKey, signedRoot.Root, signedRoot.Signature); err != nil || signedRoot.Root.RootHash != root.Hash() {
+			log.Printf("stored root for %s does not match; resuming from partial trie\n", address)
+			signedRoot = nil
+		}
+	}
 
 	return &Mirror{
 		ctx: ctx,
@@ -141,8 +183,11 @@ func NewMirror(ctx context.Context, coordinator *Coordinator, conn *wire.KeyTree
 
 		coordinator: coordinator,
 
-		root: initial,
+		// Removed from the dedup when replaced, like fetched tries.
+		root:       coordinator.dedup.Add(root),
+		signedRoot: signedRoot,
 
+		store:    store,
 		follower: follower,
 	}
 }
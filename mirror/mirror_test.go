@@ -0,0 +1,132 @@
+package mirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/trie"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// A memStore holds one stored trie and reports updates to it.
+type memStore struct {
+	signedRoot *wire.SignedRoot
+	root       *trie.Node
+	updates    chan *wire.SignedRoot
+}
+
+func (s *memStore) LoadMirror(publicKey string) (*wire.SignedRoot, *trie.Node, error) {
+	return s.signedRoot, s.root, nil
+}
+
+func (s *memStore) UpdateMirror(publicKey string, signedRoot *wire.SignedRoot, leaves []*wire.TrieLeaf) error {
+	s.updates <- signedRoot
+	return nil
+}
+
+type event struct {
+	kind     string
+	rootHash crypto.Hash
+}
+
+type chanFollower chan event
+
+func (f chanFollower) FullSync(s *wire.SignedRoot, n *trie.Node) {
+	f <- event{"full", n.Hash()}
+}
+
+func (f chanFollower) PartialSync(s *wire.SignedRoot, n *trie.Node) {
+	f <- event{"partial", n.Hash()}
+}
+
+func (f chanFollower) Updated(s *wire.SignedRoot, n *trie.Node, u []*wire.TrieLeaf) {
+	f <- event{"updated", n.Hash()}
+}
+
+func leaf(name string) *wire.TrieLeaf {
+	return &wire.TrieLeaf{
+		NameHash:  crypto.HashString(name),
+		EntryHash: crypto.HashString("entry " + name),
+	}
+}
+
+func signRoot(signer *crypto.Signer, root *trie.Node, timestamp uint64) *wire.SignedRoot {
+	r := &wire.Root{RootHash: root.Hash(), Timestamp: timestamp}
+	return &wire.SignedRoot{Root: r, Signature: signer.Sign(r)}
+}
+
+func TestMirrorResumes(t *testing.T) {
+	public, private := crypto.GenerateRandomEd25519Keypair()
+	signer, err := crypto.NewSigner(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := (*trie.Node)(nil).Apply(leaf("a")).Apply(leaf("b"))
+	storedRoot := signRoot(signer, stored, 1)
+	update := leaf("c")
+	next := stored.Apply(update)
+	nextRoot := signRoot(signer, next, 2)
+
+	// The server only knows how to catch up from the stored root; a mirror
+	// that starts from scratch would have to fetch the whole trie.
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/keytree/updatebatch" && r.URL.Query().Get("hash") == stored.Hash().String() {
+			wire.ReplyJSON(w, &wire.UpdateBatch{NewRoot: nextRoot, Updates: []*wire.TrieLeaf{update}})
+			return
+		}
+		<-done
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	store := &memStore{signedRoot: storedRoot, root: stored, updates: make(chan *wire.SignedRoot, 10)}
+	follower := make(chanFollower, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMirror(ctx, NewCoordinator(trie.NewDedup(), 0), wire.NewKeyTreeClient(server.URL), server.URL, public, store, follower)
+
+	finished := make(chan error)
+	go func() {
+		finished <- m.Run()
+	}()
+
+	if e := <-follower; e.kind != "partial" || e.rootHash != stored.Hash() {
+		t.Errorf("expected partial sync of stored trie first; got %v", e)
+	}
+	if e := <-follower; e.kind != "updated" || e.rootHash != next.Hash() {
+		t.Errorf("expected update from stored trie; got %v", e)
+	}
+	if signedRoot := <-store.updates; signedRoot == nil || signedRoot.Root.RootHash != next.Hash() {
+		t.Errorf("expected new root to be stored; got %v", signedRoot)
+	}
+
+	cancel()
+	close(done)
+	<-finished
+}
+
+func TestMirrorIgnoresBadStoredRoot(t *testing.T) {
+	public, _ := crypto.GenerateRandomEd25519Keypair()
+	_, otherPrivate := crypto.GenerateRandomEd25519Keypair()
+	signer, err := crypto.NewSigner(otherPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := (*trie.Node)(nil).Apply(leaf("a"))
+	store := &memStore{signedRoot: signRoot(signer, stored, 1), root: stored}
+
+	m := NewMirror(context.Background(), NewCoordinator(trie.NewDedup(), 0), nil, "", public, store, nil)
+	if m.signedRoot != nil {
+		t.Errorf("expected root signed by another key to be dropped")
+	}
+	if m.root.Hash() != stored.Hash() {
+		t.Errorf("expected stored trie to be kept as a partial trie")
+	}
+}
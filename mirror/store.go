@@ -0,0 +1,19 @@
+package mirror
+
+import (
+	"github.com/jellevandenhooff/keytree/trie"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// A Store persists what a Mirror has fetched, so that after a restart it can
+// resume tracking instead of downloading the whole trie again.
+type Store interface {
+	// LoadMirror returns the stored trie for publicKey, and the signed root
+	// it matches. The root is nil if the trie is only partially fetched, and
+	// both are nil if nothing is stored.
+	LoadMirror(publicKey string) (*wire.SignedRoot, *trie.Node, error)
+
+	// UpdateMirror applies leaves to the stored trie for publicKey, and
+	// records signedRoot (which may be nil) as the root it now matches.
+	UpdateMirror(publicKey string, signedRoot *wire.SignedRoot, leaves []*wire.TrieLeaf) error
+}
@@ -0,0 +1,73 @@
+package names
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// domainProfile maps domains like a lookup would, but keeps allowing
+// underscores as Keytree always has.
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// NormalizeEmail returns the canonical form of an email address: the local
+// part lower cased and in NFC, and the domain as lower case IDNA A-labels.
+// Addresses that differ only in case or Unicode representation have the same
+// canonical form.
+func NormalizeEmail(address string) (string, error) {
+	idx := strings.LastIndex(address, "@")
+	if idx == -1 {
+		return "", errors.New("expected @")
+	}
+	local, domain := address[:idx], address[idx+1:]
+
+	if !utf8.ValidString(local) {
+		return "", errors.New("local part is not valid UTF-8")
+	}
+	// Only simple lower casing: full case folding would map distinct
+	// mailboxes such as "straße" and "strasse" to the same name.
+	local = norm.NFC.String(strings.ToLower(norm.NFC.String(local)))
+
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return "", err
+	}
+
+	return local + "@" + domain, nil
+}
+
+// NormalizeDomain returns the canonical form of a domain as lower case IDNA
+// A-labels.
+func NormalizeDomain(domain string) (string, error) {
+	return domainProfile.ToASCII(domain)
+}
+
+// NormalizeName returns the canonical form of a name. Only email: and
+// domain: names have a canonical form; other names are returned as is.
+func NormalizeName(name string) (string, error) {
+	if strings.HasPrefix(name, "email:") {
+		email, err := NormalizeEmail(strings.TrimPrefix(name, "email:"))
+		if err != nil {
+			return "", err
+		}
+		return "email:" + email, nil
+	}
+
+	if strings.HasPrefix(name, "domain:") {
+		domain, err := NormalizeDomain(strings.TrimPrefix(name, "domain:"))
+		if err != nil {
+			return "", err
+		}
+		return "domain:" + domain, nil
+	}
+
+	return name, nil
+}
@@ -0,0 +1,29 @@
+package names
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	cases := map[string]string{
+		"alice@example.com":    "alice@example.com",
+		"Alice@Example.COM":    "alice@example.com",
+		"jörg@Bücher.example":  "jörg@xn--bcher-kva.example",
+		"JÖRG@bücher.example": "jörg@xn--bcher-kva.example",
+		"Straße@example.com":   "straße@example.com",
+		"STRASSE@example.com":  "strasse@example.com",
+	}
+
+	for in, expected := range cases {
+		out, err := NormalizeEmail(in)
+		if err != nil {
+			t.Errorf("unexpected error normalizing %s: %s", in, err)
+			continue
+		}
+		if out != expected {
+			t.Errorf("normalizing %s: expected %s; got %s", in, expected, out)
+		}
+	}
+
+	if _, err := NormalizeEmail("alice"); err == nil {
+		t.Errorf("expected error for address without @")
+	}
+}
@@ -0,0 +1,286 @@
+package oidcproof
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	keytreecrypto "github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/names"
+)
+
+// How far an ID token's issue time may be after the entry it proves.
+const MaxIssueDelay = 15 * 60
+
+// A JWK is a public key from an issuer's JSON Web Key Set. Only RSA and P-256
+// keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// An Issuer is an OpenID provider whose ID tokens are trusted to vouch for
+// email addresses. Its keys are pinned, rather than fetched, so every server
+// verifies tokens the same way. When the provider rotates its keys, add the
+// new ones but keep the old ones pinned: updates proved with tokens signed by
+// a removed key can no longer be verified by peers replaying history.
+type Issuer struct {
+	Issuer   string // the iss claim
+	Audience string // the client ID tokens must be issued to
+	JWKS     *JWKS
+}
+
+// Digest summarizes the issuer and its pinned keys.
+func (i *Issuer) Digest() keytreecrypto.Hash {
+	h := keytreecrypto.NewHasher()
+	h.WriteString(i.Issuer)
+	h.WriteString(i.Audience)
+
+	var keys []*JWK
+	if i.JWKS != nil {
+		keys = i.JWKS.Keys
+	}
+	h.WriteUint64(uint64(len(keys)))
+	for _, key := range keys {
+		for _, field := range []string{key.Kty, key.Kid, key.Alg, key.N, key.E, key.Crv, key.X, key.Y} {
+			h.WriteString(field)
+		}
+	}
+	return h.Sum()
+}
+
+// A Statement is what an ID token must prove.
+type Statement struct {
+	Email string
+	Nonce string
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience is a JWT aud claim, which is either a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = audience(list)
+	return nil
+}
+
+// verified is a JWT email_verified claim, which some providers send as a
+// string.
+type verified bool
+
+func (v *verified) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*v = verified(b)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*v = verified(s == "true")
+	return nil
+}
+
+type claims struct {
+	Issuer        string   `json:"iss"`
+	Audience      audience `json:"aud"`
+	IssuedAt      uint64   `json:"iat"`
+	Expiry        uint64   `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified verified `json:"email_verified"`
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func (k *JWK) verify(alg string, signed, signature []byte) error {
+	if k.Alg != "" && k.Alg != alg {
+		return fmt.Errorf("key is for '%s', not '%s'", k.Alg, alg)
+	}
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case "RS256":
+		if k.Kty != "RSA" {
+			return errors.New("RS256 needs an RSA key")
+		}
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return errors.New("bad RSA exponent")
+		}
+		key := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+
+	case "ES256":
+		if k.Kty != "EC" || k.Crv != "P-256" {
+			return errors.New("ES256 needs a P-256 key")
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return err
+		}
+		if len(signature) != 64 {
+			return errors.New("bad ES256 signature length")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errors.New("bad ES256 signature")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+}
+
+func findKey(issuer *Issuer, kid string) *JWK {
+	if issuer.JWKS == nil {
+		return nil
+	}
+	for _, key := range issuer.JWKS.Keys {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckToken verifies that token is an ID token from one of issuers proving
+// statement for an entry with the given timestamp. Expiry is checked against
+// the timestamp rather than the current time, so that the proof can be
+// verified again when replaying history.
+func CheckToken(token string, statement *Statement, issuers []*Issuer, timestamp uint64) error {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return errors.New("id token is not a signed jwt")
+	}
+
+	var h header
+	if err := decodeSegment(segments[0], &h); err != nil {
+		return fmt.Errorf("bad id token header: %s", err)
+	}
+	var c claims
+	if err := decodeSegment(segments[1], &c); err != nil {
+		return fmt.Errorf("bad id token claims: %s", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return fmt.Errorf("bad id token signature: %s", err)
+	}
+
+	var issuer *Issuer
+	for _, candidate := range issuers {
+		if candidate.Issuer == c.Issuer {
+			issuer = candidate
+		}
+	}
+	if issuer == nil {
+		return fmt.Errorf("untrusted issuer '%s'", c.Issuer)
+	}
+
+	key := findKey(issuer, h.Kid)
+	if key == nil {
+		return fmt.Errorf("unknown key '%s' for issuer '%s'", h.Kid, c.Issuer)
+	}
+	if err := key.verify(h.Alg, []byte(segments[0]+"."+segments[1]), signature); err != nil {
+		return err
+	}
+
+	if !contains(c.Audience, issuer.Audience) {
+		return fmt.Errorf("id token not issued to '%s'", issuer.Audience)
+	}
+	if timestamp > c.Expiry {
+		return errors.New("id token expired before entry was made")
+	}
+	if c.IssuedAt > timestamp+MaxIssueDelay {
+		return errors.New("id token issued too long after entry was made")
+	}
+	if c.Nonce != statement.Nonce {
+		return fmt.Errorf("incorrect nonce '%s', expecting '%s'", c.Nonce, statement.Nonce)
+	}
+	if !c.EmailVerified {
+		return errors.New("email in id token is not verified")
+	}
+
+	email, err := names.NormalizeEmail(c.Email)
+	if err != nil {
+		return err
+	}
+	if email != statement.Email {
+		return fmt.Errorf("incorrect email '%s', expecting '%s'", email, statement.Email)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package oidcproof
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+// A fakeIssuer signs ID tokens with an ES256 and an RS256 key.
+type fakeIssuer struct {
+	ecKey  *ecdsa.PrivateKey
+	rsaKey *rsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeIssuer{ecKey: ecKey, rsaKey: rsaKey}
+}
+
+func encodeInt(i *big.Int, size int) string {
+	bytes := i.Bytes()
+	if size > 0 {
+		bytes = i.FillBytes(make([]byte, size))
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func (f *fakeIssuer) issuer() *Issuer {
+	return &Issuer{
+		Issuer:   "https://issuer.example",
+		Audience: "keytree",
+		JWKS: &JWKS{Keys: []*JWK{
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: encodeInt(f.ecKey.X, 32), Y: encodeInt(f.ecKey.Y, 32)},
+			{Kty: "RSA", Kid: "rsa", N: encodeInt(f.rsaKey.N, 0), E: encodeInt(big.NewInt(int64(f.rsaKey.E)), 0)},
+		}},
+	}
+}
+
+func (f *fakeIssuer) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	alg := map[string]string{"ec": "ES256", "rsa": "RS256"}[kid]
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, f.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, f.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestCheckToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuers := []*Issuer{issuer.issuer()}
+	statement := &Statement{Email: "alice@example.com", Nonce: "abcdef"}
+	const timestamp = 1000000
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            "https://issuer.example",
+			"aud":            "keytree",
+			"iat":            timestamp + 10,
+			"exp":            timestamp + 3600,
+			"nonce":          "abcdef",
+			"email":          "Alice@Example.com",
+			"email_verified": true,
+		}
+		for k, v := range changes {
+			c[k] = v
+		}
+		return c
+	}
+
+	for _, kid := range []string{"ec", "rsa"} {
+		if err := CheckToken(issuer.token(t, kid, claims(nil)), statement, issuers, timestamp); err != nil {
+			t.Errorf("valid %s token rejected: %s", kid, err)
+		}
+	}
+
+	if err := CheckToken(issuer.token(t, "ec", claims(map[string]interface{}{
+		"aud":            []string{"other", "keytree"},
+		"email_verified": "true",
+	})), statement, issuers, timestamp); err != nil {
+		t.Errorf("valid token with list audience rejected: %s", err)
+	}
+
+	bad := map[string]map[string]interface{}{
+		"wrong issuer":   {"iss": "https://other.example"},
+		"wrong audience": {"aud": "other"},
+		"expired":        {"exp": timestamp - 1},
+		"issued late":    {"iat": timestamp + MaxIssueDelay + 1},
+		"wrong nonce":    {"nonce": "other"},
+		"wrong email":    {"email": "bob@example.com"},
+		"unverified":     {"email_verified": false},
+	}
+	for name, changes := range bad {
+		if err := CheckToken(issuer.token(t, "ec", claims(changes)), statement, issuers, timestamp); err == nil {
+			t.Errorf("%s token accepted", name)
+		}
+	}
+
+	other := newFakeIssuer(t)
+	if err := CheckToken(other.token(t, "ec", claims(nil)), statement, issuers, timestamp); err == nil {
+		t.Errorf("token signed by unpinned key accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/domainproof"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// An EntryReader reads the current entry of a name, and the entry a name had
+// at a timestamp: the latest one with a timestamp at or before it. Both are nil
+// if there is none.
+type EntryReader interface {
+	ReadEntry(name string) (*wire.Entry, error)
+	ReadEntryAt(name string, timestamp uint64) (*wire.Entry, error)
+}
+
+// checkDomainProof accepts an update for a domain name if it carries an
+// attestation of the domain's TXT record.
+func (v *Verifier) checkDomainProof(domain string, update *wire.SignedEntry) error {
+	statement := &wire.DomainStatement{
+		Domain: domain,
+		Token:  TokenForEntry(update.Entry),
+	}
+
+	signature, found := update.Signatures["domain"]
+	if !found {
+		return errors.New("no domain signature")
+	}
+	return domainproof.CheckAttestation(signature, statement, v.attesters)
+}
+
+// keytreeKeys returns the public keys of the keytree: keys in keys.
+func keytreeKeys(keys map[string]string) map[string]bool {
+	public := make(map[string]bool)
+	for name, key := range keys {
+		if strings.HasPrefix(name, "keytree:") {
+			public[key] = true
+		}
+	}
+	return public
+}
+
+// checkDomainDelegation accepts an update for an email name if it is signed by
+// a keytree key of the domain: record for the email's domain, both as that
+// record stood when the update was made and as it stands now. The first keeps
+// keys added later from vouching for earlier updates; the second keeps a key
+// that was just removed from signing updates backdated to before its removal.
+func (v *Verifier) checkDomainDelegation(email string, update *wire.SignedEntry) error {
+	if v.entries == nil {
+		return errors.New("no domain delegation")
+	}
+
+	idx := strings.LastIndex(email, "@")
+	if idx == -1 {
+		return errors.New("expected @")
+	}
+	domain := email[idx+1:]
+
+	policy, err := v.entries.ReadEntryAt("domain:"+domain, update.Entry.Timestamp)
+	if err != nil {
+		return err
+	}
+	if policy == nil || policy.Deleted || policy.InRecovery {
+		return fmt.Errorf("no domain policy for %s", domain)
+	}
+
+	current, err := v.entries.ReadEntry("domain:" + domain)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Deleted || current.InRecovery {
+		return fmt.Errorf("no domain policy for %s", domain)
+	}
+	currentKeys := keytreeKeys(current.ValidKeys(update.Entry.Timestamp))
+
+	for key := range keytreeKeys(policy.ValidKeys(update.Entry.Timestamp)) {
+		if !currentKeys[key] {
+			continue
+		}
+		signature, found := update.Signatures[key]
+		if !found {
+			continue
+		}
+		if err := crypto.Verify(key, update.Entry, signature); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no valid signature by a keytree key of domain:%s", domain)
+}
@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// ThresholdKey names the key holding the number of keytree: signatures
+// needed to change a record. Without it, a single keytree: signature
+// suffices.
+const ThresholdKey = "keytree-threshold"
+
+func checkThresholdValue(value string) error {
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if threshold < 1 {
+		return errors.New("threshold must be >= 1")
+	}
+	return nil
+}
+
+func init() {
+	RegisterKeyValidator(ThresholdKey, checkThresholdValue)
+}
+
+// Threshold returns the number of keytree: signatures needed to change
+// entry, or 0 if entry does not declare a threshold.
+func Threshold(entry *wire.Entry) int {
+	value, found := entry.Keys[ThresholdKey]
+	if !found {
+		return 0
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return threshold
+}
+
+// countKeytreeKeys returns the number of distinct public keys among the
+// keytree: keys of keys named in names, or among all its keytree: keys if
+// names is nil. Several names holding the same key count once, so that one
+// guardian can't meet a threshold alone.
+func countKeytreeKeys(keys map[string]string, names map[string]bool) int {
+	distinct := make(map[string]bool)
+	for name, key := range keys {
+		if strings.HasPrefix(name, "keytree:") && (names == nil || names[name]) {
+			distinct[key] = true
+		}
+	}
+	return len(distinct)
+}
+
+// CheckThreshold makes sure a threshold declared by entry can be met by its
+// keytree: keys, with room left under policy's signature limit for an
+// ownership proof, which some updates need as well.
+func CheckThreshold(entry *wire.Entry, policy *Policy) error {
+	threshold := Threshold(entry)
+	if threshold == 0 {
+		return nil
+	}
+
+	if threshold > policy.MaxSignatures-1 {
+		return fmt.Errorf("threshold %d exceeds maximum %d", threshold, policy.MaxSignatures-1)
+	}
+
+	guardians := countKeytreeKeys(entry.Keys, nil)
+	if guardians < threshold {
+		return fmt.Errorf("threshold %d exceeds number of keytree keys %d", threshold, guardians)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+)
+
+// A KeyValidator checks the value of a key in a known namespace.
+type KeyValidator func(value string) error
+
+// keyValidators maps key name prefixes to their validator. Keys with an
+// unknown prefix are free-form. Guarded by keyValidatorsMu, since verifiers
+// read it concurrently.
+var keyValidatorsMu sync.RWMutex
+var keyValidators = map[string]KeyValidator{
+	"ssh:":     checkSSHKey,
+	"box:":     crypto.CheckBoxPublicKey,
+	"keytree:": crypto.CheckEd25519PublicKey,
+	"pgp:":     checkPGPKey,
+}
+
+// RegisterKeyValidator adds a validator for all keys starting with prefix.
+// Register validators at init only: servers that disagree on validators
+// reject each other's updates, and registering after updates were accepted
+// changes the outcome of replaying them.
+func RegisterKeyValidator(prefix string, validator KeyValidator) {
+	keyValidatorsMu.Lock()
+	defer keyValidatorsMu.Unlock()
+
+	keyValidators[prefix] = validator
+}
+
+func keyValidatorPrefixes() []string {
+	keyValidatorsMu.RLock()
+	defer keyValidatorsMu.RUnlock()
+
+	prefixes := make([]string, 0, len(keyValidators))
+	for prefix := range keyValidators {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+func CheckKeyValue(name, value string) error {
+	keyValidatorsMu.RLock()
+	defer keyValidatorsMu.RUnlock()
+
+	for prefix, validator := range keyValidators {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if err := validator(value); err != nil {
+			return fmt.Errorf("bad value for key '%s': %s", name, err)
+		}
+	}
+	return nil
+}
+
+func checkSSHKey(value string) error {
+	_, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(value))
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(rest))) != 0 {
+		return errors.New("expected a single authorized_keys line")
+	}
+	return nil
+}
+
+func checkPGPKey(value string) error {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(value))
+	if err != nil {
+		return err
+	}
+	if len(entities) != 1 {
+		return errors.New("expected exactly one armored key")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+)
+
+func armoredPGPKey(t *testing.T) string {
+	entity, err := openpgp.NewEntity("Alice", "", "alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.String()
+}
+
+func TestCheckKeyValue(t *testing.T) {
+	edPublic, _ := crypto.GenerateRandomEd25519Keypair()
+	boxPublic, _ := crypto.GenerateRandomBoxKeypair()
+
+	good := map[string]string{
+		"keytree:laptop": edPublic,
+		"box:laptop":     boxPublic,
+		"ssh:laptop":     "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGbxb1dP3e4Pmo2QvFXuPCHeXc5L8dyzwZ6Am5bUtLjb laptop",
+		"pgp:laptop":     armoredPGPKey(t),
+		"other:laptop":   "garbage",
+	}
+	for name, value := range good {
+		if err := CheckKeyValue(name, value); err != nil {
+			t.Errorf("unexpected error for %s: %s", name, err)
+		}
+	}
+
+	bad := map[string]string{
+		"keytree:laptop": boxPublic,
+		"box:laptop":     edPublic,
+		"ssh:laptop":     "garbage",
+		"pgp:laptop":     "garbage",
+	}
+	for name, value := range bad {
+		if err := CheckKeyValue(name, value); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
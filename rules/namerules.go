@@ -2,26 +2,35 @@ package rules
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jellevandenhooff/keytree/names"
 )
 
 const allowedKeyNameCharacters = "abcdefghijklmnopqrstuvwxyz1234567890-_:"
 
 const allowedKeyValueCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890=-+_.,:@()/\\\"' \r\n"
 
-func CheckKey(name, value string) error {
+func (p *Policy) CheckKey(name, value string) error {
 	for _, c := range name {
-		if strings.IndexRune(allowedKeyNameCharacters, c) == -1 {
+		if strings.IndexRune(p.AllowedKeyNameCharacters, c) == -1 {
 			return errors.New("bad key name character")
 		}
 	}
 
 	for _, c := range value {
-		if strings.IndexRune(allowedKeyValueCharacters, c) == -1 {
+		if strings.IndexRune(p.AllowedKeyValueCharacters, c) == -1 {
 			return errors.New("bad key value character")
 		}
 	}
 
+	if err := CheckKeyValue(name, value); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -30,6 +39,14 @@ func CheckName(name string) error {
 		return err
 	}
 
+	if err := CheckHTTPS(name); err != nil {
+		return err
+	}
+
+	if err := CheckDomain(name); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -43,6 +60,14 @@ func CheckEmail(name string) error {
 
 	email := strings.TrimPrefix(name, "email:")
 
+	normalized, err := names.NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if normalized != email {
+		return fmt.Errorf("email must be in canonical form '%s'", normalized)
+	}
+
 	parts := strings.Split(email, "@")
 	if len(parts) != 2 {
 		return errors.New("expected one @")
@@ -52,6 +77,10 @@ func CheckEmail(name string) error {
 	domain := parts[1]
 
 	for _, c := range local {
+		if c >= utf8.RuneSelf && (unicode.IsLetter(c) || unicode.IsNumber(c) || unicode.IsMark(c)) {
+			// Allow internationalized (SMTPUTF8) local parts.
+			continue
+		}
 		if strings.IndexRune(allowedLocalCharacters, c) == -1 {
 			return errors.New("bad email character")
 		}
@@ -69,3 +98,59 @@ func CheckEmail(name string) error {
 
 	return nil
 }
+
+func CheckHTTPS(name string) error {
+	if !strings.HasPrefix(name, "https:") {
+		return nil
+	}
+
+	origin := strings.TrimPrefix(name, "https:")
+
+	if len(origin) == 0 {
+		return errors.New("missing origin")
+	}
+
+	for _, c := range origin {
+		if strings.IndexRune(allowedDomainCharacters, c) == -1 {
+			return errors.New("bad origin character")
+		}
+	}
+
+	if origin[0] == '.' || origin[len(origin)-1] == '.' {
+		return errors.New("origin must not start or end in .")
+	}
+
+	return nil
+}
+
+func CheckDomain(name string) error {
+	if !strings.HasPrefix(name, "domain:") {
+		return nil
+	}
+
+	domain := strings.TrimPrefix(name, "domain:")
+
+	normalized, err := names.NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	if normalized != domain {
+		return fmt.Errorf("domain must be in canonical form '%s'", normalized)
+	}
+
+	if len(domain) == 0 {
+		return errors.New("missing domain")
+	}
+
+	for _, c := range domain {
+		if strings.IndexRune(allowedDomainCharacters, c) == -1 {
+			return errors.New("bad domain character")
+		}
+	}
+
+	if domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return errors.New("domain must not start or end in .")
+	}
+
+	return nil
+}
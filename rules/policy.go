@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/dkimproof"
+)
+
+// A Policy holds the limits a server enforces on updates. Servers that
+// replicate each other must use the same policy, or they will reject each
+// other's updates; compare Digest to detect a mismatch.
+type Policy struct {
+	MaxNameLength       int
+	MaxKeys             int
+	MaxKeyNameLength    int
+	MaxKeyValueLength   int
+	MaxTotalValueLength int
+
+	MaxSignatures                 int
+	MaxSignatureNameLength        int
+	MaxSignatureValueLength       int
+	MaxDKIMSignatureValueLength   int
+	MaxHTTPSSignatureValueLength  int
+	MaxSMIMESignatureValueLength  int
+	MaxOIDCSignatureValueLength   int
+	MaxDomainSignatureValueLength int
+
+	AllowedKeyNameCharacters  string
+	AllowedKeyValueCharacters string
+
+	// Allow names of the form 'test:' without proof of ownership.
+	AllowTestNames bool
+
+	// Which DKIM signatures prove ownership of an email address; nil means
+	// the default.
+	DKIM *dkimproof.Policy `json:",omitempty"`
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxNameLength:       MaxNameLength,
+		MaxKeys:             MaxKeys,
+		MaxKeyNameLength:    MaxKeyNameLength,
+		MaxKeyValueLength:   MaxKeyValueLength,
+		MaxTotalValueLength: MaxTotalValueLength,
+
+		MaxSignatures:                 MaxSignatures,
+		MaxSignatureNameLength:        MaxSignatureNameLength,
+		MaxSignatureValueLength:       MaxSignatureValueLength,
+		MaxDKIMSignatureValueLength:   MaxDKIMSignatureValueLength,
+		MaxHTTPSSignatureValueLength:  MaxHTTPSSignatureValueLength,
+		MaxSMIMESignatureValueLength:  MaxSMIMESignatureValueLength,
+		MaxOIDCSignatureValueLength:   MaxOIDCSignatureValueLength,
+		MaxDomainSignatureValueLength: MaxDomainSignatureValueLength,
+
+		AllowedKeyNameCharacters:  allowedKeyNameCharacters,
+		AllowedKeyValueCharacters: allowedKeyValueCharacters,
+
+		AllowTestNames: true,
+
+		DKIM: dkimproof.DefaultPolicy(),
+	}
+}
+
+func (p *Policy) Digest() crypto.Hash {
+	h := crypto.NewHasher()
+
+	h.WriteUint64(uint64(p.MaxNameLength))
+	h.WriteUint64(uint64(p.MaxKeys))
+	h.WriteUint64(uint64(p.MaxKeyNameLength))
+	h.WriteUint64(uint64(p.MaxKeyValueLength))
+	h.WriteUint64(uint64(p.MaxTotalValueLength))
+
+	h.WriteUint64(uint64(p.MaxSignatures))
+	h.WriteUint64(uint64(p.MaxSignatureNameLength))
+	h.WriteUint64(uint64(p.MaxSignatureValueLength))
+	h.WriteUint64(uint64(p.MaxDKIMSignatureValueLength))
+	h.WriteUint64(uint64(p.MaxHTTPSSignatureValueLength))
+	h.WriteUint64(uint64(p.MaxSMIMESignatureValueLength))
+	h.WriteUint64(uint64(p.MaxOIDCSignatureValueLength))
+	h.WriteUint64(uint64(p.MaxDomainSignatureValueLength))
+
+	h.WriteString(p.AllowedKeyNameCharacters)
+	h.WriteString(p.AllowedKeyValueCharacters)
+
+	h.WriteBool(p.AllowTestNames)
+
+	dkimDigest := p.DKIM.Digest()
+	h.Write(dkimDigest.Bytes())
+
+	// Key validators are not configurable, but peers running different
+	// versions may know different ones.
+	prefixes := keyValidatorPrefixes()
+	h.WriteUint64(uint64(len(prefixes)))
+	for _, prefix := range prefixes {
+		h.WriteString(prefix)
+	}
+
+	return h.Sum()
+}
@@ -1,63 +1,86 @@
 package rules
 
 import (
-	"errors"
+	"fmt"
 
 	"github.com/jellevandenhooff/keytree/wire"
 )
 
+// Default limits; servers can override them through a Policy.
 const MaxNameLength = 1024
 const MaxKeys = 64
 const MaxKeyNameLength = 64
 const MaxKeyValueLength = 4096
 const MaxTotalValueLength = 8192
 
-func SizeCheckEntry(entry *wire.Entry) error {
-	if len(entry.Name) > MaxNameLength {
-		return errors.New("bad name; len must be <= MaxNameLength")
+func (p *Policy) SizeCheckEntry(entry *wire.Entry) error {
+	if len(entry.Name) > p.MaxNameLength {
+		return fmt.Errorf("bad name; len must be <= %d", p.MaxNameLength)
 	}
-	if len(entry.Keys) > MaxKeys {
-		return errors.New("bad keys; len must be <= MaxKeys")
+	if len(entry.Keys) > p.MaxKeys {
+		return fmt.Errorf("bad keys; len must be <= %d", p.MaxKeys)
 	}
 
 	total := 0
 	for name, value := range entry.Keys {
-		if len(name) > MaxKeyNameLength {
-			return errors.New("bad key name; len must be <= MaxKeyNameLength")
+		if len(name) > p.MaxKeyNameLength {
+			return fmt.Errorf("bad key name; len must be <= %d", p.MaxKeyNameLength)
 		}
-		if len(value) > MaxKeyValueLength {
-			return errors.New("bad key value; len must be <= MaxKeyValueLength")
+		if len(value) > p.MaxKeyValueLength {
+			return fmt.Errorf("bad key value; len must be <= %d", p.MaxKeyValueLength)
 		}
 		total += len(value)
 	}
-	if total > MaxTotalValueLength {
-		return errors.New("bad keys; total value len must be <= MaxTotalValueLength")
+	if total > p.MaxTotalValueLength {
+		return fmt.Errorf("bad keys; total value len must be <= %d", p.MaxTotalValueLength)
 	}
 
 	return nil
 }
 
-const MaxSignatures = 4
+// Leaves room for threshold signatures next to an ownership proof.
+const MaxSignatures = 8
 const MaxSignatureNameLength = 128
 const MaxSignatureValueLength = 128
-const MaxDKIMSignatureValueLength = 4096
+const MaxDKIMSignatureValueLength = 8192 // canonical headers and archived DNS key
+const MaxHTTPSSignatureValueLength = 1024
+const MaxSMIMESignatureValueLength = 16384 // signed content and certificate chain
+const MaxOIDCSignatureValueLength = 4096
+const MaxDomainSignatureValueLength = 1024
 
-func SizeCheckSignatures(signatures map[string]string) error {
-	if len(signatures) > MaxSignatures {
-		return errors.New("bad signatures; len must be <= MaxSignatures")
+func (p *Policy) SizeCheckSignatures(signatures map[string]string) error {
+	if len(signatures) > p.MaxSignatures {
+		return fmt.Errorf("bad signatures; len must be <= %d", p.MaxSignatures)
 	}
 
 	for name, value := range signatures {
-		if len(name) > MaxSignatureNameLength {
-			return errors.New("bad signature name; len must be <= MaxSignatureNameLength")
+		if len(name) > p.MaxSignatureNameLength {
+			return fmt.Errorf("bad signature name; len must be <= %d", p.MaxSignatureNameLength)
 		}
-		if name != "dkim" {
-			if len(value) > MaxSignatureValueLength {
-				return errors.New("bad signature value; len must be <= MaxSignatureValueLength")
+		switch name {
+		case "dkim":
+			if len(value) > p.MaxDKIMSignatureValueLength {
+				return fmt.Errorf("bad dkim signature value; len must be <= %d", p.MaxDKIMSignatureValueLength)
 			}
-		} else {
-			if len(value) > MaxDKIMSignatureValueLength {
-				return errors.New("bad dkim signature value; len must be <= MaxDKIMSignatureValueLength")
+		case "smime":
+			if len(value) > p.MaxSMIMESignatureValueLength {
+				return fmt.Errorf("bad smime signature value; len must be <= %d", p.MaxSMIMESignatureValueLength)
+			}
+		case "oidc":
+			if len(value) > p.MaxOIDCSignatureValueLength {
+				return fmt.Errorf("bad oidc signature value; len must be <= %d", p.MaxOIDCSignatureValueLength)
+			}
+		case "domain":
+			if len(value) > p.MaxDomainSignatureValueLength {
+				return fmt.Errorf("bad domain signature value; len must be <= %d", p.MaxDomainSignatureValueLength)
+			}
+		case "https":
+			if len(value) > p.MaxHTTPSSignatureValueLength {
+				return fmt.Errorf("bad https signature value; len must be <= %d", p.MaxHTTPSSignatureValueLength)
+			}
+		default:
+			if len(value) > p.MaxSignatureValueLength {
+				return fmt.Errorf("bad signature value; len must be <= %d", p.MaxSignatureValueLength)
 			}
 		}
 	}
@@ -1,8 +1,10 @@
 package rules
 
 import (
+	"crypto/x509"
 	"errors"
-	"flag"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/jellevandenhooff/dkim"
@@ -10,6 +12,9 @@ import (
 	"github.com/jellevandenhooff/keytree/crypto"
 	"github.com/jellevandenhooff/keytree/dkimproof"
 	"github.com/jellevandenhooff/keytree/encoding/base32"
+	"github.com/jellevandenhooff/keytree/httpsproof"
+	"github.com/jellevandenhooff/keytree/oidcproof"
+	"github.com/jellevandenhooff/keytree/smimeproof"
 	"github.com/jellevandenhooff/keytree/wire"
 )
 
@@ -19,18 +24,98 @@ const RecoverWaitTime = 4 * OneDayInSeconds
 const TokenBits = 128
 const TokenLen = TokenBits / 8
 
-var allowTestNames = flag.Bool("allow-test-names", true, "Allow names of the form 'test:' without proof of ownership.")
-
 type Verifier struct {
 	dnsClient dkim.DNSClient
+	policy    *Policy
+
+	// public keys of servers whose https, domain and dkim key attestations
+	// are accepted
+	attesters map[string]bool
+
+	// check dkim proofs against archived keys, for replaying history
+	archivedDKIMKeys bool
+
+	// authorities trusted to issue s/mime certificates; may be nil
+	smimeTrust        *x509.CertPool
+	smimeCertificates []*x509.Certificate
+
+	// openid providers trusted to vouch for email addresses
+	oidcIssuers []*oidcproof.Issuer
+
+	// historical entries, for domain delegation; may be nil
+	entries EntryReader
 }
 
-func NewVerifier(dnsClient dkim.DNSClient) *Verifier {
+func NewVerifier(dnsClient dkim.DNSClient, policy *Policy, attesters []string, entries EntryReader) *Verifier {
+	trusted := make(map[string]bool)
+	for _, publicKey := range attesters {
+		trusted[publicKey] = true
+	}
+
 	return &Verifier{
 		dnsClient: dnsClient,
+		policy:    policy,
+		attesters: trusted,
+		entries:   entries,
 	}
 }
 
+func (v *Verifier) Policy() *Policy {
+	return v.policy
+}
+
+// SetArchivedDKIMKeys makes the verifier check DKIM proofs against the DNS key
+// archived in the proof instead of the current one. Not threadsafe; call
+// before using the verifier.
+func (v *Verifier) SetArchivedDKIMKeys(enabled bool) {
+	v.archivedDKIMKeys = enabled
+}
+
+// SetSMIMETrustStore makes the verifier accept S/MIME proofs signed by
+// certificates issued by trusted. Like the policy, the trust store must match
+// across servers that replicate each other, so it is part of Digest. Not
+// threadsafe; call before using the verifier.
+func (v *Verifier) SetSMIMETrustStore(trusted []*x509.Certificate) {
+	v.smimeTrust = smimeproof.NewCertPool(trusted)
+	v.smimeCertificates = trusted
+}
+
+// SetOIDCIssuers makes the verifier accept ID tokens from issuers as proofs.
+// Like the trust store, the issuers are part of Digest. Not threadsafe; call
+// before using the verifier.
+func (v *Verifier) SetOIDCIssuers(issuers []*oidcproof.Issuer) {
+	v.oidcIssuers = issuers
+}
+
+// Digest summarizes everything that decides which updates the verifier
+// accepts: the policy, the S/MIME trust store and the OIDC issuers. Servers that
+// replicate each other compare digests to detect a mismatch.
+func (v *Verifier) Digest() crypto.Hash {
+	h := crypto.NewHasher()
+
+	policyDigest := v.policy.Digest()
+	h.Write(policyDigest.Bytes())
+
+	// The order of certificates in the trust store file doesn't matter.
+	certHashes := make([]string, len(v.smimeCertificates))
+	for i, cert := range v.smimeCertificates {
+		certHashes[i] = crypto.HashString(string(cert.Raw)).String()
+	}
+	sort.Strings(certHashes)
+	h.WriteUint64(uint64(len(certHashes)))
+	for _, certHash := range certHashes {
+		h.WriteString(certHash)
+	}
+
+	h.WriteUint64(uint64(len(v.oidcIssuers)))
+	for _, issuer := range v.oidcIssuers {
+		issuerDigest := issuer.Digest()
+		h.Write(issuerDigest.Bytes())
+	}
+
+	return h.Sum()
+}
+
 func TokenForEntry(entry *wire.Entry) string {
 	return base32.EncodeToString(entry.Hash().Bytes()[:TokenLen])
 }
@@ -41,10 +126,6 @@ func (v *Verifier) CheckProofOfOwnership(update *wire.SignedEntry) error {
 	token := TokenForEntry(update.Entry)
 
 	if strings.HasPrefix(name, "email:") {
-		signature, found := update.Signatures["dkim"]
-		if !found {
-			return errors.New("no dkim signature")
-		}
 		email := strings.TrimPrefix(name, "email:")
 
 		statement := &wire.DKIMStatement{
@@ -52,8 +133,40 @@ func (v *Verifier) CheckProofOfOwnership(update *wire.SignedEntry) error {
 			Token:  token,
 		}
 
-		return dkimproof.CheckPlainEmail(signature, statement, v.dnsClient)
-	} else if strings.HasPrefix(name, "test:") && *allowTestNames {
+		if signature, found := update.Signatures["dkim"]; found {
+			return dkimproof.CheckProof(signature, statement, v.policy.DKIM, v.dnsClient, v.attesters, v.archivedDKIMKeys)
+		}
+		if signature, found := update.Signatures["smime"]; found {
+			return smimeproof.CheckProof(signature, statement, v.smimeTrust, update.Entry.Timestamp)
+		}
+		if signature, found := update.Signatures["oidc"]; found {
+			return oidcproof.CheckToken(signature, &oidcproof.Statement{
+				Email: email,
+				Nonce: token,
+			}, v.oidcIssuers, update.Entry.Timestamp)
+		}
+
+		// Without a DKIM, S/MIME or OIDC proof, the domain's admins can vouch
+		// for the update.
+		if err := v.checkDomainDelegation(email, update); err != nil {
+			return fmt.Errorf("no dkim, smime or oidc signature (%s)", err)
+		}
+		return nil
+	} else if strings.HasPrefix(name, "domain:") {
+		return v.checkDomainProof(strings.TrimPrefix(name, "domain:"), update)
+	} else if strings.HasPrefix(name, "https:") {
+		signature, found := update.Signatures["https"]
+		if !found {
+			return errors.New("no https signature")
+		}
+
+		statement := &wire.HTTPSStatement{
+			Origin: strings.TrimPrefix(name, "https:"),
+			Token:  token,
+		}
+
+		return httpsproof.CheckAttestation(signature, statement, v.attesters)
+	} else if strings.HasPrefix(name, "test:") && v.policy.AllowTestNames {
 		// accept test names without complaining!
 		signature, found := update.Signatures["test"]
 		if !found {
@@ -76,6 +189,27 @@ func (w *Window) Contains(t uint64) bool {
 	return w.Start <= t && t < w.End
 }
 
+// IsRecoveryCancel returns whether entry takes old out of recovery without
+// changing anything else.
+func IsRecoveryCancel(old, entry *wire.Entry) bool {
+	if old == nil || !old.InRecovery || entry.InRecovery || entry.Deleted {
+		return false
+	}
+
+	if len(old.Keys) != len(entry.Keys) {
+		return false
+	}
+	for name, key := range old.Keys {
+		if value, found := entry.Keys[name]; !found || value != key {
+			return false
+		}
+		if !sameValidity(old.KeyValidity[name], entry.KeyValidity[name]) {
+			return false
+		}
+	}
+	return true
+}
+
 type changeInfo struct {
 	validSignatures          map[string]bool
 	changedKeys              map[string]bool
@@ -83,11 +217,20 @@ type changeInfo struct {
 	hasValidKeytreeSignature bool
 	hasChangedKeytreeKey     bool
 	hasValidOwnershipProof   bool
+	ownershipProofError      error
+
+	// threshold of the old entry, and whether enough keytree signatures
+	// meet it
+	threshold         int
+	hasGuardianQuorum bool
 }
 
 func (v *Verifier) getChangeInfo(old *wire.Entry, update *wire.SignedEntry) *changeInfo {
+	// Keys outside their validity window can't sign.
+	validKeys := old.ValidKeys(update.Entry.Timestamp)
+
 	validSignatures := make(map[string]bool)
-	for name, key := range old.Keys {
+	for name, key := range validKeys {
 		signature, found := update.Signatures[key]
 		if !found {
 			continue
@@ -109,7 +252,20 @@ func (v *Verifier) getChangeInfo(old *wire.Entry, update *wire.SignedEntry) *cha
 			changedKeys[name] = true
 		}
 	}
+	for name := range old.KeyValidity {
+		if !sameValidity(old.KeyValidity[name], update.Entry.KeyValidity[name]) {
+			changedKeys[name] = true
+		}
+	}
+	for name := range update.Entry.KeyValidity {
+		if !sameValidity(old.KeyValidity[name], update.Entry.KeyValidity[name]) {
+			changedKeys[name] = true
+		}
+	}
 
+	// Keytree keys lock the record even outside their validity windows;
+	// once they have all expired, a proof alone still has to go through
+	// recovery.
 	hadKeytreeKey := false
 	for name := range old.Keys {
 		if strings.HasPrefix(name, "keytree:") {
@@ -131,7 +287,10 @@ func (v *Verifier) getChangeInfo(old *wire.Entry, update *wire.SignedEntry) *cha
 		}
 	}
 
-	hasValidOwnershipProof := v.CheckProofOfOwnership(update) == nil
+	ownershipProofError := v.CheckProofOfOwnership(update)
+
+	threshold := Threshold(old)
+	hasGuardianQuorum := threshold > 0 && countKeytreeKeys(validKeys, validSignatures) >= threshold
 
 	return &changeInfo{
 		validSignatures:          validSignatures,
@@ -139,8 +298,60 @@ func (v *Verifier) getChangeInfo(old *wire.Entry, update *wire.SignedEntry) *cha
 		hadKeytreeKey:            hadKeytreeKey,
 		hasValidKeytreeSignature: hasValidKeytreeSignature,
 		hasChangedKeytreeKey:     hasChangedKeytreeKey,
-		hasValidOwnershipProof:   hasValidOwnershipProof,
+		hasValidOwnershipProof:   ownershipProofError == nil,
+		ownershipProofError:      ownershipProofError,
+		threshold:                threshold,
+		hasGuardianQuorum:        hasGuardianQuorum,
+	}
+}
+
+func sameValidity(a, b *wire.Validity) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// record copies the inputs of the decision from info.
+func (info *changeInfo) record(decision *wire.UpdateDecision) {
+	decision.ValidSignatures = sortedNames(info.validSignatures)
+	decision.ChangedKeys = sortedNames(info.changedKeys)
+	decision.HadKeytreeKey = info.hadKeytreeKey
+	decision.HasValidKeytreeSignature = info.hasValidKeytreeSignature
+	decision.HasChangedKeytreeKey = info.hasChangedKeytreeKey
+	decision.HasValidOwnershipProof = info.hasValidOwnershipProof
+	if info.ownershipProofError != nil {
+		decision.OwnershipProofError = info.ownershipProofError.Error()
+	}
+	decision.Threshold = info.threshold
+	decision.HasGuardianQuorum = info.hasGuardianQuorum
+}
+
+// check records the outcome of a rule in decision, and returns an error
+// with message failure if the rule did not pass.
+func check(decision *wire.UpdateDecision, rule string, passed bool, failure string) error {
+	outcome := &wire.RuleOutcome{
+		Rule:   rule,
+		Passed: passed,
+	}
+	decision.Rules = append(decision.Rules, outcome)
+
+	if passed {
+		return nil
+	}
+
+	outcome.Failure = failure
+	decision.Error = failure
+	return errors.New(failure)
 }
 
 var baseEntry = &wire.Entry{
@@ -151,63 +362,149 @@ var baseEntry = &wire.Entry{
 }
 
 func (v *Verifier) VerifyUpdate(old *wire.Entry, update *wire.SignedEntry, now Window) error {
+	_, err := v.ExplainUpdate(old, update, now)
+	return err
+}
+
+// ExplainUpdate verifies update like VerifyUpdate, and also returns a record
+// of the inputs and every rule evaluated up to and including the first
+// failing rule.
+func (v *Verifier) ExplainUpdate(old *wire.Entry, update *wire.SignedEntry, now Window) (*wire.UpdateDecision, error) {
 	if old == nil {
 		old = baseEntry
 	}
 
-	if !now.Contains(update.Entry.Timestamp) {
-		return errors.New("bad timestamp; must be in window")
+	decision := &wire.UpdateDecision{}
+
+	if err := check(decision, "timestamp-in-window", now.Contains(update.Entry.Timestamp),
+		"bad timestamp; must be in window"); err != nil {
+		return decision, err
+	}
+
+	if err := check(decision, "timestamp-increases", old.Timestamp < update.Entry.Timestamp,
+		"bad timestamp; must be > old timestamp"); err != nil {
+		return decision, err
 	}
 
-	if old.Timestamp >= update.Entry.Timestamp {
-		return errors.New("bad timestamp; must be > old timestamp")
+	if update.Entry.Deleted {
+		if err := check(decision, "delete-needs-record", old != baseEntry && !old.Deleted,
+			"can't delete a record that does not exist"); err != nil {
+			return decision, err
+		}
 	}
 
 	info := v.getChangeInfo(old, update)
+	info.record(decision)
+
+	// The owner can always cancel recovery with the signatures needed to
+	// change keys, so that they can respond to a takeover attempt within
+	// RecoverWaitTime. Other ways out of recovery follow the rules below.
+	if IsRecoveryCancel(old, update.Entry) {
+		authorized := info.hasValidKeytreeSignature
+		if info.threshold > 0 {
+			authorized = info.hasGuardianQuorum
+		}
+		if authorized {
+			decision.CancelsRecovery = true
+			decision.Accepted = true
+			return decision, nil
+		}
+	}
 
 	overrideSignatureRequirement := false
 
 	if old.InRecovery {
-		if !info.hasValidOwnershipProof {
-			return errors.New("need valid proof of ownership if record in recovery")
+		if err := check(decision, "in-recovery-needs-proof", info.hasValidOwnershipProof,
+			"need valid proof of ownership if record in recovery"); err != nil {
+			return decision, err
 		}
 
 		if old.Timestamp+RecoverWaitTime < update.Entry.Timestamp {
 			overrideSignatureRequirement = true
+			decision.RecoveryOverride = true
 		}
 	}
 
 	if update.Entry.InRecovery {
-		if !info.hasValidOwnershipProof {
-			return errors.New("need valid proof of ownership to put record in recovery")
+		if err := check(decision, "enter-recovery-needs-proof", info.hasValidOwnershipProof,
+			"need valid proof of ownership to put record in recovery"); err != nil {
+			return decision, err
 		}
 
-		if len(info.changedKeys) > 0 {
-			return errors.New("can't change keys if record is in recovery")
+		if err := check(decision, "enter-recovery-keeps-keys", len(info.changedKeys) == 0,
+			"can't change keys if record is in recovery"); err != nil {
+			return decision, err
 		}
 	}
 
 	if !info.hadKeytreeKey {
-		if !info.hasValidOwnershipProof {
-			return errors.New("record without keytree keys needs valid proof of ownership")
+		if err := check(decision, "unlocked-record-needs-proof", info.hasValidOwnershipProof,
+			"record without keytree keys needs valid proof of ownership"); err != nil {
+			return decision, err
 		}
 
 		overrideSignatureRequirement = true
 	}
 
-	if len(info.changedKeys) > 0 && !info.hasValidKeytreeSignature && !overrideSignatureRequirement {
-		return errors.New("need valid signature without valid override")
+	decision.OverrideSignatureRequirement = overrideSignatureRequirement
+
+	if len(info.changedKeys) > 0 {
+		if info.threshold > 0 {
+			if err := check(decision, "changed-keys-need-threshold-signatures", info.hasGuardianQuorum || overrideSignatureRequirement,
+				fmt.Sprintf("need %d valid keytree signatures without valid override", info.threshold)); err != nil {
+				return decision, err
+			}
+		} else {
+			if err := check(decision, "changed-keys-need-signature", info.hasValidKeytreeSignature || overrideSignatureRequirement,
+				"need valid signature without valid override"); err != nil {
+				return decision, err
+			}
+		}
 	}
 
-	if info.hasChangedKeytreeKey && !info.validSignatures["keytree:recovery"] && !info.hasValidOwnershipProof {
-		return errors.New("need proof of ownership to change a keytree key")
+	if info.hasChangedKeytreeKey {
+		if err := check(decision, "changed-keytree-key-needs-proof", info.validSignatures["keytree:recovery"] || info.hasGuardianQuorum || info.hasValidOwnershipProof,
+			"need proof of ownership to change a keytree key"); err != nil {
+			return decision, err
+		}
 	}
 
+	decision.Accepted = true
+	return decision, nil
+}
+
+// CheckTombstone makes sure a deleted entry carries nothing but its name and
+// timestamp.
+func CheckTombstone(entry *wire.Entry) error {
+	if !entry.Deleted {
+		return nil
+	}
+
+	if len(entry.Keys) > 0 {
+		return errors.New("deleted entry must not have keys")
+	}
+	if entry.InRecovery {
+		return errors.New("deleted entry must not be in recovery")
+	}
+	return nil
+}
+
+// CheckKeyValidity makes sure every validity window belongs to a key and is
+// not empty.
+func CheckKeyValidity(entry *wire.Entry) error {
+	for name, validity := range entry.KeyValidity {
+		if _, found := entry.Keys[name]; !found {
+			return fmt.Errorf("validity for missing key '%s'", name)
+		}
+		if validity.NotBefore != 0 && validity.NotAfter != 0 && validity.NotBefore >= validity.NotAfter {
+			return fmt.Errorf("empty validity for key '%s'", name)
+		}
+	}
 	return nil
 }
 
-func CheckEntry(entry *wire.Entry) error {
-	if err := SizeCheckEntry(entry); err != nil {
+func CheckEntry(entry *wire.Entry, policy *Policy) error {
+	if err := policy.SizeCheckEntry(entry); err != nil {
 		return err
 	}
 
@@ -215,8 +512,20 @@ func CheckEntry(entry *wire.Entry) error {
 		return err
 	}
 
+	if err := CheckThreshold(entry, policy); err != nil {
+		return err
+	}
+
+	if err := CheckTombstone(entry); err != nil {
+		return err
+	}
+
+	if err := CheckKeyValidity(entry); err != nil {
+		return err
+	}
+
 	for name, value := range entry.Keys {
-		if err := CheckKey(name, value); err != nil {
+		if err := policy.CheckKey(name, value); err != nil {
 			return err
 		}
 	}
@@ -224,12 +533,12 @@ func CheckEntry(entry *wire.Entry) error {
 	return nil
 }
 
-func CheckUpdate(update *wire.SignedEntry) error {
-	if err := CheckEntry(update.Entry); err != nil {
+func CheckUpdate(update *wire.SignedEntry, policy *Policy) error {
+	if err := CheckEntry(update.Entry, policy); err != nil {
 		return err
 	}
 
-	if err := SizeCheckSignatures(update.Signatures); err != nil {
+	if err := policy.SizeCheckSignatures(update.Signatures); err != nil {
 		return err
 	}
 
@@ -0,0 +1,525 @@
+package rules
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agl/ed25519"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/dns"
+	"github.com/jellevandenhooff/keytree/oidcproof"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func TestExplainUpdate(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	now := Window{Start: 0, End: 1000}
+
+	entry := &wire.Entry{
+		Name:      "test:alice",
+		Keys:      map[string]string{"other:laptop": "hello"},
+		Timestamp: 100,
+	}
+
+	decision, err := v.ExplainUpdate(nil, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{},
+	}, now)
+	if err == nil || decision.Accepted {
+		t.Fatalf("expected update without proof to be rejected")
+	}
+	last := decision.Rules[len(decision.Rules)-1]
+	if last.Rule != "unlocked-record-needs-proof" || last.Passed {
+		t.Errorf("expected unlocked-record-needs-proof to fail; got %s", last.Rule)
+	}
+	if decision.OwnershipProofError == "" {
+		t.Errorf("expected ownership proof error")
+	}
+
+	decision, err = v.ExplainUpdate(nil, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{"test": TokenForEntry(entry)},
+	}, now)
+	if err != nil || !decision.Accepted {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(decision.ChangedKeys) != 1 || decision.ChangedKeys[0] != "other:laptop" {
+		t.Errorf("expected other:laptop to be changed; got %v", decision.ChangedKeys)
+	}
+	if !decision.OverrideSignatureRequirement {
+		t.Errorf("expected signature requirement to be overridden")
+	}
+}
+
+func TestThresholdSignatures(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	now := Window{Start: 0, End: 1000}
+
+	publicA, privateA := crypto.GenerateRandomEd25519Keypair()
+	publicB, privateB := crypto.GenerateRandomEd25519Keypair()
+
+	old := &wire.Entry{
+		Name: "test:alice",
+		Keys: map[string]string{
+			"keytree:a":  publicA,
+			"keytree:b":  publicB,
+			ThresholdKey: "2",
+		},
+		Timestamp: 100,
+	}
+
+	entry := &wire.Entry{
+		Name:      old.Name,
+		Keys:      map[string]string{"other:laptop": "hello"},
+		Timestamp: 200,
+	}
+	for name, key := range old.Keys {
+		entry.Keys[name] = key
+	}
+
+	signatureA, _ := crypto.Sign(privateA, entry)
+	signatureB, _ := crypto.Sign(privateB, entry)
+
+	if err := v.VerifyUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{publicA: signatureA},
+	}, now); err == nil {
+		t.Errorf("expected one of two signatures to be rejected")
+	}
+
+	if err := v.VerifyUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{publicA: signatureA, publicB: signatureB},
+	}, now); err != nil {
+		t.Errorf("unexpected error with two of two signatures: %s", err)
+	}
+
+	// The same key under two names is still one guardian.
+	duplicated := &wire.Entry{
+		Name: old.Name,
+		Keys: map[string]string{
+			"keytree:a":  publicA,
+			"keytree:a2": publicA,
+			ThresholdKey: "2",
+		},
+		Timestamp: 100,
+	}
+	if err := CheckThreshold(duplicated, DefaultPolicy()); err == nil {
+		t.Errorf("expected threshold over duplicated keys to be rejected")
+	}
+
+	// Every guardian signing must fit next to an ownership proof.
+	many := &wire.Entry{Name: old.Name, Keys: map[string]string{ThresholdKey: "8"}, Timestamp: 100}
+	for i := 0; i < 8; i++ {
+		public, _ := crypto.GenerateRandomEd25519Keypair()
+		many.Keys[fmt.Sprintf("keytree:%d", i)] = public
+	}
+	if err := CheckThreshold(many, DefaultPolicy()); err == nil {
+		t.Errorf("expected threshold over signature limit to be rejected")
+	}
+	many.Keys[ThresholdKey] = "7"
+	if err := CheckThreshold(many, DefaultPolicy()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	entry = &wire.Entry{
+		Name:      old.Name,
+		Keys:      map[string]string{"other:laptop": "hello"},
+		Timestamp: 200,
+	}
+	signatureA, _ = crypto.Sign(privateA, entry)
+	if err := v.VerifyUpdate(duplicated, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{publicA: signatureA},
+	}, now); err == nil {
+		t.Errorf("expected one signature by a duplicated key to be rejected")
+	}
+}
+
+func TestTombstone(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	now := Window{Start: 0, End: 1000}
+
+	old := &wire.Entry{
+		Name:      "test:alice",
+		Keys:      map[string]string{"other:laptop": "hello"},
+		Timestamp: 100,
+	}
+	tombstone := &wire.Entry{
+		Name:      old.Name,
+		Keys:      map[string]string{},
+		Timestamp: 200,
+		Deleted:   true,
+	}
+	update := &wire.SignedEntry{
+		Entry:      tombstone,
+		Signatures: map[string]string{"test": TokenForEntry(tombstone)},
+	}
+
+	if err := CheckUpdate(update, DefaultPolicy()); err != nil {
+		t.Errorf("unexpected error checking tombstone: %s", err)
+	}
+	if err := v.VerifyUpdate(old, update, now); err != nil {
+		t.Errorf("unexpected error deleting: %s", err)
+	}
+	if err := v.VerifyUpdate(nil, update, now); err == nil {
+		t.Errorf("expected error deleting missing record")
+	}
+	if !tombstone.ToLeaf().IsTombstone() {
+		t.Errorf("expected tombstone leaf")
+	}
+}
+
+func TestCancelRecovery(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	now := Window{Start: 0, End: 1000}
+
+	public, private := crypto.GenerateRandomEd25519Keypair()
+
+	old := &wire.Entry{
+		Name:       "test:alice",
+		Keys:       map[string]string{"keytree:recovery": public},
+		Timestamp:  100,
+		InRecovery: true,
+	}
+	entry := &wire.Entry{
+		Name:      old.Name,
+		Keys:      map[string]string{"keytree:recovery": public},
+		Timestamp: 200,
+	}
+	signature, _ := crypto.Sign(private, entry)
+
+	decision, err := v.ExplainUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{public: signature},
+	}, now)
+	if err != nil {
+		t.Fatalf("unexpected error cancelling recovery: %s", err)
+	}
+	if !decision.CancelsRecovery {
+		t.Errorf("expected decision to cancel recovery")
+	}
+
+	if err := v.VerifyUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{},
+	}, now); err == nil {
+		t.Errorf("expected error cancelling recovery without signature")
+	}
+
+	// Without a signature, leaving recovery takes the usual proof.
+	late := *entry
+	late.Timestamp = old.Timestamp + RecoverWaitTime + 1
+	if err := v.VerifyUpdate(old, &wire.SignedEntry{
+		Entry:      &late,
+		Signatures: map[string]string{"test": TokenForEntry(&late)},
+	}, Window{Start: 0, End: late.Timestamp + 1}); err != nil {
+		t.Errorf("unexpected error leaving recovery with proof after the wait time: %s", err)
+	}
+
+	unlocked := &wire.Entry{Name: old.Name, Keys: map[string]string{}, Timestamp: 100, InRecovery: true}
+	left := &wire.Entry{Name: old.Name, Keys: map[string]string{}, Timestamp: 200}
+	if err := v.VerifyUpdate(unlocked, &wire.SignedEntry{
+		Entry:      left,
+		Signatures: map[string]string{"test": TokenForEntry(left)},
+	}, now); err != nil {
+		t.Errorf("unexpected error leaving recovery of unlocked record: %s", err)
+	}
+
+	// With guardians, one of them can't cancel recovery alone.
+	publicB, _ := crypto.GenerateRandomEd25519Keypair()
+	guarded := &wire.Entry{
+		Name:       old.Name,
+		Keys:       map[string]string{"keytree:a": public, "keytree:b": publicB, ThresholdKey: "2"},
+		Timestamp:  100,
+		InRecovery: true,
+	}
+	cancel := &wire.Entry{Name: old.Name, Keys: guarded.Keys, Timestamp: 200}
+	signature, _ = crypto.Sign(private, cancel)
+	if err := v.VerifyUpdate(guarded, &wire.SignedEntry{
+		Entry:      cancel,
+		Signatures: map[string]string{public: signature},
+	}, now); err == nil {
+		t.Errorf("expected error cancelling recovery below threshold")
+	}
+}
+
+func TestExpiredSigner(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	now := Window{Start: 0, End: 1000}
+
+	public, private := crypto.GenerateRandomEd25519Keypair()
+
+	old := &wire.Entry{
+		Name: "test:alice",
+		Keys: map[string]string{"keytree:laptop": public},
+		KeyValidity: map[string]*wire.Validity{
+			"keytree:laptop": {NotAfter: 150},
+		},
+		Timestamp: 100,
+	}
+	entry := &wire.Entry{
+		Name: old.Name,
+		Keys: map[string]string{
+			"keytree:laptop": public,
+			"other:laptop":   "hello",
+		},
+		KeyValidity: old.KeyValidity,
+		Timestamp:   200,
+	}
+	signature, _ := crypto.Sign(private, entry)
+
+	decision, _ := v.ExplainUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{public: signature},
+	}, now)
+	if len(decision.ValidSignatures) != 0 {
+		t.Errorf("expected expired key not to count as signer; got %v", decision.ValidSignatures)
+	}
+
+	// The expired key still locks the record against a bare proof.
+	if err := v.VerifyUpdate(old, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{"test": TokenForEntry(entry)},
+	}, now); err == nil {
+		t.Errorf("expected proof alone to be rejected for record with expired keytree key")
+	}
+}
+
+// fakeEntryReader holds each name's entries in timestamp order.
+type fakeEntryReader map[string][]*wire.Entry
+
+func (f fakeEntryReader) ReadEntry(name string) (*wire.Entry, error) {
+	if entries := f[name]; len(entries) > 0 {
+		return entries[len(entries)-1], nil
+	}
+	return nil, nil
+}
+
+func (f fakeEntryReader) ReadEntryAt(name string, timestamp uint64) (*wire.Entry, error) {
+	var found *wire.Entry
+	for _, entry := range f[name] {
+		if entry.Timestamp <= timestamp {
+			found = entry
+		}
+	}
+	return found, nil
+}
+
+func TestDomainDelegation(t *testing.T) {
+	public, private := crypto.GenerateRandomEd25519Keypair()
+
+	newPublic, newPrivate := crypto.GenerateRandomEd25519Keypair()
+
+	// The domain adds a key after the update; replaying the update must
+	// still check it against the keys it had at the time.
+	entries := fakeEntryReader{
+		"domain:example.com": []*wire.Entry{
+			&wire.Entry{
+				Name:      "domain:example.com",
+				Keys:      map[string]string{"keytree:it": public},
+				Timestamp: 50,
+			},
+			&wire.Entry{
+				Name:      "domain:example.com",
+				Keys:      map[string]string{"keytree:it": public, "keytree:new": newPublic},
+				Timestamp: 150,
+			},
+		},
+	}
+	v := NewVerifier(nil, DefaultPolicy(), nil, entries)
+	now := Window{Start: 0, End: 1000}
+
+	entry := &wire.Entry{
+		Name:      "email:alice@example.com",
+		Keys:      map[string]string{"other:laptop": "hello"},
+		Timestamp: 100,
+	}
+	signature, _ := crypto.Sign(private, entry)
+
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{public: signature},
+	}, now); err != nil {
+		t.Errorf("unexpected error with domain signature: %s", err)
+	}
+
+	signature, _ = crypto.Sign(newPrivate, entry)
+
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{newPublic: signature},
+	}, now); err == nil {
+		t.Errorf("expected error for key added after the update")
+	}
+
+	early := &wire.Entry{
+		Name:      "email:alice@example.com",
+		Keys:      entry.Keys,
+		Timestamp: 40,
+	}
+	signature, _ = crypto.Sign(private, early)
+
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      early,
+		Signatures: map[string]string{public: signature},
+	}, now); err == nil {
+		t.Errorf("expected error for update made before the domain policy")
+	}
+
+	other := &wire.Entry{
+		Name:      "email:alice@example.org",
+		Keys:      entry.Keys,
+		Timestamp: 100,
+	}
+	signature, _ = crypto.Sign(private, other)
+
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      other,
+		Signatures: map[string]string{public: signature},
+	}, now); err == nil {
+		t.Errorf("expected error for domain without policy")
+	}
+
+	// Once the domain removes a key, the key can no longer sign updates,
+	// not even ones backdated to before its removal.
+	rotated := fakeEntryReader{
+		"domain:example.com": []*wire.Entry{
+			entries["domain:example.com"][0],
+			&wire.Entry{
+				Name:      "domain:example.com",
+				Keys:      map[string]string{"keytree:new": newPublic},
+				Timestamp: 150,
+			},
+		},
+	}
+	v = NewVerifier(nil, DefaultPolicy(), nil, rotated)
+	signature, _ = crypto.Sign(private, entry)
+
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{public: signature},
+	}, now); err == nil {
+		t.Errorf("expected error for backdated update signed by a removed key")
+	}
+}
+
+func TestVerifierDigest(t *testing.T) {
+	v := NewVerifier(nil, DefaultPolicy(), nil, nil)
+	digest := v.Digest()
+
+	a := &x509.Certificate{Raw: []byte("a")}
+	b := &x509.Certificate{Raw: []byte("b")}
+
+	v.SetSMIMETrustStore([]*x509.Certificate{a, b})
+	withTrustStore := v.Digest()
+	if withTrustStore == digest {
+		t.Errorf("expected trust store to change digest")
+	}
+
+	v.SetSMIMETrustStore([]*x509.Certificate{b, a})
+	if v.Digest() != withTrustStore {
+		t.Errorf("expected digest not to depend on certificate order")
+	}
+
+	v.SetOIDCIssuers([]*oidcproof.Issuer{{Issuer: "https://accounts.example.com", Audience: "keytree"}})
+	if v.Digest() == withTrustStore {
+		t.Errorf("expected oidc issuers to change digest")
+	}
+}
+
+// signEd25519 signs headers, given in relaxed canonical form, with an
+// ed25519-sha256 DKIM signature.
+func signEd25519(privateKey *[ed25519.PrivateKeySize]byte, domain, selector string, headers []string) string {
+	var names []string
+	for _, header := range headers {
+		names = append(names, header[:strings.Index(header, ":")])
+	}
+	signature := fmt.Sprintf("dkim-signature:v=1; a=ed25519-sha256; c=relaxed/relaxed; d=%s; s=%s; h=%s; b=", domain, selector, strings.Join(names, ":"))
+
+	h := sha256.New()
+	for _, header := range headers {
+		h.Write([]byte(header + "\r\n"))
+	}
+	h.Write([]byte(signature))
+	sig := ed25519.Sign(privateKey, h.Sum(nil))
+
+	return strings.Join(headers, "\r\n") + "\r\n" + signature + base64.StdEncoding.EncodeToString(sig[:]) + "\r\n"
+}
+
+func TestDKIMUpdate(t *testing.T) {
+	fixture, err := dns.LoadFixture(filepath.Join("testdata", "dns.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(dns.NewReplayClient(fixture), DefaultPolicy(), nil, nil)
+
+	bytes, err := ioutil.ReadFile(filepath.Join("testdata", "entry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry *wire.Entry
+	if err := json.Unmarshal(bytes, &entry); err != nil {
+		t.Fatal(err)
+	}
+	now := Window{Start: entry.Timestamp - 60, End: entry.Timestamp + 60}
+
+	// The captured email was sent from Gmail to keytree.io in March 2015. Its
+	// signature, sender and the policy check out, but its subject holds the
+	// token for an entry of that time.
+	bytes, err = ioutil.ReadFile(filepath.Join("testdata", "dkim.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := &wire.SignedEntry{
+		Entry:      entry,
+		Signatures: map[string]string{"dkim": strings.Replace(string(bytes), "\n", "\r\n", -1)},
+	}
+	if err := CheckUpdate(captured, DefaultPolicy()); err != nil {
+		t.Fatalf("unexpected error checking update: %s", err)
+	}
+	if err := v.CheckProofOfOwnership(captured); err == nil || !strings.Contains(err.Error(), "missing token") {
+		t.Errorf("expected only the token to be missing; got %v", err)
+	}
+	if err := v.VerifyUpdate(nil, captured, now); err == nil {
+		t.Errorf("expected email with old token to be rejected")
+	}
+
+	// An email with the right token, signed with a key added to the fixture.
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture.Answers["test._domainkey.vandenhooff.name."] = &dns.FixtureAnswer{
+		Records: []string{"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(publicKey[:])},
+	}
+	update := &wire.SignedEntry{
+		Entry: entry,
+		Signatures: map[string]string{"dkim": signEd25519(privateKey, "vandenhooff.name", "test", []string{
+			"from:Jelle van den Hooff <jelle@vandenhooff.name>",
+			"subject:" + TokenForEntry(entry),
+		})},
+	}
+	if err := v.VerifyUpdate(nil, update, now); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// The email proves ownership of this entry only.
+	changed := *entry
+	changed.Keys = map[string]string{"other:laptop": "goodbye"}
+	if err := v.VerifyUpdate(nil, &wire.SignedEntry{
+		Entry:      &changed,
+		Signatures: update.Signatures,
+	}, now); err == nil {
+		t.Errorf("expected email for other entry to be rejected")
+	}
+}
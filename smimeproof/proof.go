@@ -0,0 +1,235 @@
+package smimeproof
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+
+	"github.com/jellevandenhooff/keytree/names"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+// How far an S/MIME signing time may be from the entry it proves.
+const MaxSigningTimeSkew = 15 * 60
+
+// ErrNotSigned is returned by ExtractProof for mail without an S/MIME
+// signature.
+var ErrNotSigned = errors.New("mail is not s/mime signed")
+
+// canonicalize converts line endings to CRLF, the form in which S/MIME
+// content is signed.
+func canonicalize(s string) string {
+	return strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\n", "\r\n", -1)
+}
+
+// ExtractProof extracts the signed content and detached signature from a
+// multipart/signed email. Opaque application/pkcs7-mime messages are not
+// supported.
+func ExtractProof(raw string) (*wire.SMIMEProof, error) {
+	msg, err := mail.ReadMessage(strings.NewReader(canonicalize(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/signed" {
+		return nil, ErrNotSigned
+	}
+	protocol := strings.ToLower(params["protocol"])
+	if protocol != "application/pkcs7-signature" && protocol != "application/x-pkcs7-signature" {
+		return nil, ErrNotSigned
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("missing multipart boundary")
+	}
+
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each part is preceded by CRLF--boundary; the first one possibly
+	// without the CRLF.
+	parts := strings.Split("\r\n"+string(body), "\r\n--"+boundary)
+	if len(parts) < 4 || !strings.HasPrefix(parts[3], "--") {
+		return nil, errors.New("expected exactly two parts in signed mail")
+	}
+
+	// Skip the rest of the boundary line.
+	var content, signaturePart string
+	for i, part := range parts[1:3] {
+		newline := strings.Index(part, "\r\n")
+		if newline == -1 {
+			return nil, errors.New("bad multipart boundary line")
+		}
+		if i == 0 {
+			content = part[newline+2:]
+		} else {
+			signaturePart = part[newline+2:]
+		}
+	}
+
+	signatureMsg, err := mail.ReadMessage(strings.NewReader(signaturePart))
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := ioutil.ReadAll(signatureMsg.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(signatureMsg.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return nil, errors.New("expected base64 encoded signature")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encoded)), ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return &wire.SMIMEProof{
+		Content:   content,
+		Signature: signature,
+	}, nil
+}
+
+func EncodeProof(proof *wire.SMIMEProof) (string, error) {
+	bytes, err := json.Marshal(proof)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func DecodeProof(signature string) (*wire.SMIMEProof, error) {
+	var proof *wire.SMIMEProof
+	if err := json.Unmarshal([]byte(signature), &proof); err != nil {
+		return nil, err
+	}
+	if err := proof.Check(); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// LoadTrustStore reads PEM-encoded certificates of the authorities trusted to
+// issue S/MIME certificates.
+func LoadTrustStore(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return certs, nil
+}
+
+// NewCertPool returns a pool holding certs.
+func NewCertPool(certs []*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+func allowsEmailProtection(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageEmailProtection || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckProof verifies that signature is an encoded proof for statement: the
+// content must contain the token, and be signed by a certificate for the
+// sender that chains to trusted. The chain is checked at timestamp, the time
+// of the entry, so that peers replaying the update later reach the same
+// verdict; the signing time must be close to it.
+func CheckProof(signature string, statement *wire.DKIMStatement, trusted *x509.CertPool, timestamp uint64) error {
+	if trusted == nil {
+		return errors.New("no s/mime trust store configured")
+	}
+
+	proof, err := DecodeProof(signature)
+	if err != nil {
+		return fmt.Errorf("could not decode s/mime proof: %s", err)
+	}
+
+	p7, err := pkcs7.Parse(proof.Signature)
+	if err != nil {
+		return err
+	}
+	p7.Content = []byte(proof.Content)
+
+	if len(p7.Signers) != 1 {
+		return errors.New("expected exactly one s/mime signer")
+	}
+	if err := p7.VerifyWithChainAtTime(trusted, time.Unix(int64(timestamp), 0)); err != nil {
+		return err
+	}
+
+	var signingTime time.Time
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime); err != nil {
+		return errors.New("s/mime signature has no signing time")
+	}
+	if delta := signingTime.Unix() - int64(timestamp); delta > MaxSigningTimeSkew || delta < -MaxSigningTimeSkew {
+		return errors.New("s/mime signing time is too far from entry time")
+	}
+
+	cert := p7.GetOnlySigner()
+	if cert == nil {
+		return errors.New("missing s/mime signer certificate")
+	}
+	if !allowsEmailProtection(cert) {
+		return errors.New("s/mime certificate is not for email protection")
+	}
+
+	found := false
+	for _, address := range cert.EmailAddresses {
+		if normalized, err := names.NormalizeEmail(address); err == nil && normalized == statement.Sender {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("s/mime certificate is not for '%s'", statement.Sender)
+	}
+
+	if !bytes.Contains(bytes.ToLower(p7.Content), bytes.ToLower([]byte(statement.Token))) {
+		return fmt.Errorf("missing token '%s' from signed content", statement.Token)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package smimeproof
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func newCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func signedMail(t *testing.T, content string, cert *x509.Certificate, key *ecdsa.PrivateKey) string {
+	signed, err := pkcs7.NewSignedData([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := signed.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	signed.Detach()
+	der, err := signed.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "From: Alice <alice@example.com>\r\n" +
+		"Subject: hello\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256; boundary=\"XYZ\"\r\n" +
+		"\r\n" +
+		"This is an S/MIME signed message.\r\n" +
+		"--XYZ\r\n" +
+		content +
+		"\r\n--XYZ\r\n" +
+		"Content-Type: application/pkcs7-signature; name=smime.p7s\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString(der) + "\r\n" +
+		"--XYZ--\r\n"
+}
+
+func TestProof(t *testing.T) {
+	now := time.Now()
+	ca, caKey := newCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	leaf, leafKey := newCert(t, &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "alice"},
+		NotBefore:      now.Add(-time.Hour),
+		NotAfter:       now.Add(time.Hour),
+		EmailAddresses: []string{"Alice@Example.com"},
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}, ca, caKey)
+
+	trusted := NewCertPool([]*x509.Certificate{ca})
+	timestamp := uint64(now.Unix())
+
+	content := "Content-Type: text/plain\r\n\r\nmy token is ABCDEF\r\n"
+	mail := signedMail(t, content, leaf, leafKey)
+
+	proof, err := ExtractProof(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Content != content {
+		t.Errorf("extracted content %q, expected %q", proof.Content, content)
+	}
+
+	signature, err := EncodeProof(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := &wire.DKIMStatement{Sender: "alice@example.com", Token: "abcdef"}
+	if err := CheckProof(signature, statement, trusted, timestamp); err != nil {
+		t.Errorf("valid proof rejected: %s", err)
+	}
+
+	if err := CheckProof(signature, &wire.DKIMStatement{Sender: "bob@example.com", Token: "abcdef"}, trusted, timestamp); err == nil {
+		t.Errorf("proof accepted for other sender")
+	}
+	if err := CheckProof(signature, &wire.DKIMStatement{Sender: "alice@example.com", Token: "other"}, trusted, timestamp); err == nil {
+		t.Errorf("proof accepted for other token")
+	}
+	if err := CheckProof(signature, statement, x509.NewCertPool(), timestamp); err == nil {
+		t.Errorf("proof accepted without trusted ca")
+	}
+
+	// The mail is signed shortly after the entry is made, but not long after.
+	if err := CheckProof(signature, statement, trusted, timestamp-10*60); err != nil {
+		t.Errorf("valid proof rejected for entry made before signing: %s", err)
+	}
+	if err := CheckProof(signature, statement, trusted, timestamp-2*60*60); err == nil {
+		t.Errorf("proof accepted for entry made long before signing")
+	}
+
+	// Line endings may be converted in transit.
+	if _, err := ExtractProof(strings.Replace(mail, "\r\n", "\n", -1)); err != nil {
+		t.Errorf("mail with LF line endings rejected: %s", err)
+	}
+
+	if _, err := ExtractProof("From: alice@example.com\r\n\r\nhi\r\n"); err != ErrNotSigned {
+		t.Errorf("unsigned mail returned %v, expected ErrNotSigned", err)
+	}
+}
@@ -0,0 +1,333 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Mail is a message received for one recipient.
+type Mail struct {
+	From, To string
+	Mail     string // headers and body, with the line endings as sent
+}
+
+const DefaultMaxMessageSize = 1 << 20
+const DefaultCommandTimeout = 2 * time.Minute
+const DefaultIdleTimeout = 5 * time.Minute
+
+// Limits on command lines and on the recipients of a single message.
+const maxLineLength = 1000
+const maxRecipients = 100
+
+var errTooLarge = errors.New("message exceeds maximum size")
+
+// A Server receives mail over SMTP and passes each message to Handler, once
+// per recipient. It accepts mail for any recipient; Handler decides what to
+// keep.
+type Server struct {
+	Domain  string
+	Handler func(*Mail)
+
+	// If set, STARTTLS is offered.
+	TLSConfig *tls.Config
+
+	// Limits; zero means the default.
+	MaxMessageSize int64         // per message, in bytes
+	CommandTimeout time.Duration // to receive and handle a command, including a message
+	IdleTimeout    time.Duration // to wait for the next command
+}
+
+func (s *Server) maxMessageSize() int64 {
+	if s.MaxMessageSize > 0 {
+		return s.MaxMessageSize
+	}
+	return DefaultMaxMessageSize
+}
+
+func (s *Server) commandTimeout() time.Duration {
+	if s.CommandTimeout > 0 {
+		return s.CommandTimeout
+	}
+	return DefaultCommandTimeout
+}
+
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+// Serve accepts connections on l until it fails, and closes l.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Printf("smtp accept failed: %s\n", err)
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.serve(c)
+	}
+}
+
+type conn struct {
+	server *Server
+	conn   net.Conn
+	isTLS  bool
+
+	// limit bounds how much more may be read for the current command or
+	// message.
+	limit *io.LimitedReader
+	r     *bufio.Reader
+	w     *bufio.Writer
+
+	helo    bool
+	hasFrom bool
+	from    string
+	to      []string
+}
+
+// setConn starts reading from and writing to nc. Anything still buffered from
+// the previous connection is dropped, so that plaintext sent along with
+// STARTTLS is never taken as coming over TLS.
+func (c *conn) setConn(nc net.Conn) {
+	c.conn = nc
+	c.limit = &io.LimitedReader{R: nc}
+	c.r = bufio.NewReader(c.limit)
+	c.w = bufio.NewWriter(nc)
+}
+
+func (c *conn) setDeadline(timeout time.Duration) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+}
+
+func (c *conn) reply(code int, lines ...string) error {
+	for i, line := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		fmt.Fprintf(c.w, "%d%s%s\r\n", code, sep, line)
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) reset() {
+	c.hasFrom = false
+	c.from = ""
+	c.to = nil
+}
+
+func (s *Server) serve(nc net.Conn) {
+	defer nc.Close()
+
+	c := &conn{server: s}
+	c.setConn(nc)
+
+	c.setDeadline(s.commandTimeout())
+	if err := c.reply(220, s.Domain+" ESMTP"); err != nil {
+		return
+	}
+
+	for {
+		c.setDeadline(s.idleTimeout())
+		c.limit.N = maxLineLength
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if c.limit.N == 0 {
+				c.reply(500, "line too long")
+			}
+			return
+		}
+
+		c.setDeadline(s.commandTimeout())
+		if !c.handle(strings.TrimRight(line, "\r\n")) {
+			return
+		}
+	}
+}
+
+// parsePath parses an argument such as "FROM:<alice@example.com> SIZE=1000"
+// into the address and its parameters.
+func parsePath(arg string, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(arg, '>')
+	if end == -1 {
+		return "", nil, false
+	}
+	return arg[1:end], strings.Fields(arg[end+1:]), true
+}
+
+// handle handles a command line, and reports whether to keep the connection
+// open.
+func (c *conn) handle(line string) bool {
+	verb, arg := line, ""
+	if idx := strings.IndexByte(line, ' '); idx != -1 {
+		verb, arg = line[:idx], strings.TrimSpace(line[idx+1:])
+	}
+
+	var err error
+	switch strings.ToUpper(verb) {
+	case "HELO":
+		c.helo = true
+		c.reset()
+		err = c.reply(250, c.server.Domain)
+
+	case "EHLO":
+		c.helo = true
+		c.reset()
+		extensions := []string{
+			c.server.Domain,
+			"8BITMIME",
+			fmt.Sprintf("SIZE %d", c.server.maxMessageSize()),
+		}
+		if c.server.TLSConfig != nil && !c.isTLS {
+			extensions = append(extensions, "STARTTLS")
+		}
+		err = c.reply(250, extensions...)
+
+	case "STARTTLS":
+		if c.server.TLSConfig == nil || c.isTLS {
+			err = c.reply(502, "TLS not available")
+			break
+		}
+		if err := c.reply(220, "ready to start TLS"); err != nil {
+			return false
+		}
+		tlsConn := tls.Server(c.conn, c.server.TLSConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return false
+		}
+		c.setConn(tlsConn)
+		c.isTLS = true
+		// The client must greet again, as described in RFC 3207 section 4.2.
+		c.helo = false
+		c.reset()
+
+	case "MAIL":
+		if !c.helo || c.hasFrom {
+			err = c.reply(503, "bad sequence of commands")
+			break
+		}
+		from, params, ok := parsePath(arg, "FROM:")
+		if !ok {
+			err = c.reply(501, "bad syntax")
+			break
+		}
+		for _, param := range params {
+			if strings.HasPrefix(strings.ToUpper(param), "SIZE=") {
+				size, sizeErr := strconv.ParseInt(param[len("SIZE="):], 10, 64)
+				if sizeErr == nil && size > c.server.maxMessageSize() {
+					return c.reply(552, errTooLarge.Error()) == nil
+				}
+			}
+		}
+		c.hasFrom = true
+		c.from = from
+		err = c.reply(250, "ok")
+
+	case "RCPT":
+		if !c.hasFrom {
+			err = c.reply(503, "bad sequence of commands")
+			break
+		}
+		to, _, ok := parsePath(arg, "TO:")
+		if !ok {
+			err = c.reply(501, "bad syntax")
+			break
+		}
+		if len(c.to) >= maxRecipients {
+			err = c.reply(452, "too many recipients")
+			break
+		}
+		c.to = append(c.to, to)
+		err = c.reply(250, "ok")
+
+	case "DATA":
+		if len(c.to) == 0 {
+			err = c.reply(503, "bad sequence of commands")
+			break
+		}
+		if err := c.reply(354, "end data with <CR><LF>.<CR><LF>"); err != nil {
+			return false
+		}
+		mail, dataErr := c.readData()
+		if dataErr == errTooLarge {
+			// The rest of the message is not worth reading.
+			c.reply(552, errTooLarge.Error())
+			return false
+		} else if dataErr != nil {
+			return false
+		}
+
+		if c.server.Handler != nil {
+			for _, to := range c.to {
+				c.server.Handler(&Mail{From: c.from, To: to, Mail: mail})
+			}
+		}
+		c.reset()
+		c.setDeadline(c.server.commandTimeout())
+		err = c.reply(250, "ok")
+
+	case "RSET":
+		c.reset()
+		err = c.reply(250, "ok")
+
+	case "NOOP":
+		err = c.reply(250, "ok")
+
+	case "VRFY":
+		err = c.reply(252, "cannot verify user")
+
+	case "QUIT":
+		c.reply(221, "bye")
+		return false
+
+	default:
+		err = c.reply(500, "unrecognized command")
+	}
+	return err == nil
+}
+
+// readData reads a message up to the line with a single dot, undoing dot
+// stuffing. It returns errTooLarge once the message is longer than the
+// maximum size; the limit applies to each message on its own.
+func (c *conn) readData() (string, error) {
+	c.limit.N = c.server.maxMessageSize() + int64(len(".\r\n"))
+
+	var mail bytes.Buffer
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if c.limit.N == 0 {
+				return "", errTooLarge
+			}
+			return "", err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return mail.String(), nil
+		}
+		mail.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
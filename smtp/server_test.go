@@ -0,0 +1,139 @@
+package smtp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func selfSignedConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mx.example.com"},
+		DNSNames:     []string{"mx.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func startServer(t *testing.T, s *Server) (string, chan *Mail) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := make(chan *Mail, 10)
+	s.Domain = "example.com"
+	s.Handler = func(m *Mail) {
+		mails <- m
+	}
+	go s.Serve(l)
+	return l.Addr().String(), mails
+}
+
+func send(c *smtp.Client, from, to, body string) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func TestStartTLS(t *testing.T) {
+	addr, mails := startServer(t, &Server{TLSConfig: selfSignedConfig(t)})
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		t.Fatal("expected STARTTLS to be offered")
+	}
+	if err := c.StartTLS(&tls.Config{ServerName: "mx.example.com", InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.TLSConnectionState(); !ok {
+		t.Fatal("expected a tls connection")
+	}
+
+	body := "Subject: hi\r\n\r\n.leading dot\r\n"
+	if err := send(c, "alice@example.org", "bob@example.com", body); err != nil {
+		t.Fatal(err)
+	}
+	m := <-mails
+	if m.From != "alice@example.org" || m.To != "bob@example.com" || m.Mail != body {
+		t.Errorf("unexpected mail %v", m)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	addr, mails := startServer(t, &Server{MaxMessageSize: 100})
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The limit applies to each message, not to the connection.
+	body := "Subject: hi\r\n\r\n" + strings.Repeat("x", 60) + "\r\n"
+	for i := 0; i < 3; i++ {
+		if err := send(c, "alice@example.org", "bob@example.com", body); err != nil {
+			t.Fatalf("message %d: %s", i, err)
+		}
+		<-mails
+	}
+
+	body = "Subject: hi\r\n\r\n" + strings.Repeat("x", 200) + "\r\n"
+	if err := send(c, "alice@example.org", "bob@example.com", body); err == nil {
+		t.Errorf("expected error for message exceeding the limit")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	addr, _ := startServer(t, &Server{IdleTimeout: 50 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	if greeting, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(greeting, "220 ") {
+		t.Fatalf("unexpected greeting %q: %v", greeting, err)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Errorf("expected idle connection to be closed")
+	}
+}
@@ -0,0 +1,39 @@
+package trie
+
+import (
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func diff(old, new *Node, idx int, leaves []*wire.TrieLeaf) []*wire.TrieLeaf {
+	if old.Hash() == new.Hash() {
+		return leaves
+	}
+
+	if (old == nil || old.Entry != nil) && (new == nil || new.Entry != nil) {
+		if new != nil {
+			leaves = append(leaves, new.Entry)
+		}
+		if old != nil && (new == nil || old.Entry.NameHash != new.Entry.NameHash) {
+			leaves = append(leaves, &wire.TrieLeaf{
+				NameHash:  old.Entry.NameHash,
+				EntryHash: crypto.EmptyHash,
+			})
+		}
+		return leaves
+	}
+
+	oldChildren := old.Split(idx)
+	newChildren := new.Split(idx)
+	for i := 0; i < 2; i++ {
+		leaves = diff(oldChildren[i], newChildren[i], idx+1, leaves)
+	}
+	return leaves
+}
+
+// Diff returns the leaves that turn old into new when applied with Apply;
+// names missing from new get tombstones. Subtrees with equal hashes are
+// skipped, so Diff is cheap for similar tries.
+func Diff(old, new *Node) []*wire.TrieLeaf {
+	return diff(old, new, 0, nil)
+}
@@ -0,0 +1,48 @@
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
+)
+
+func leaf(name, entry string) *wire.TrieLeaf {
+	return &wire.TrieLeaf{
+		NameHash:  crypto.HashString(name),
+		EntryHash: crypto.HashString(entry),
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var old *Node
+	for i := 0; i < 100; i++ {
+		old = old.Apply(leaf(fmt.Sprint(i), "v1"))
+	}
+
+	new := old
+	new = new.Apply(leaf("5", "v2"))
+	new = new.Apply(leaf("new", "v1"))
+	new = new.Set(crypto.HashString("17"), nil)
+
+	leaves := Diff(old, new)
+	if len(leaves) != 3 {
+		t.Errorf("expected 3 changed leaves; got %d", len(leaves))
+	}
+
+	patched := old
+	for _, leaf := range leaves {
+		patched = patched.Apply(leaf)
+	}
+	if patched.Hash() != new.Hash() {
+		t.Errorf("applying diff did not produce new trie")
+	}
+
+	if leaves := Diff(nil, new); len(leaves) != new.Leaves() {
+		t.Errorf("expected diff from empty trie to hold all %d leaves; got %d", new.Leaves(), len(leaves))
+	}
+	if leaves := Diff(new, new); len(leaves) != 0 {
+		t.Errorf("expected empty diff; got %d leaves", len(leaves))
+	}
+}
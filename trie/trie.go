@@ -77,6 +77,14 @@ func (n *Node) Set(key crypto.Hash, value *wire.TrieLeaf) *Node {
 	return n.set(key, 0, value)
 }
 
+// Apply sets leaf in the trie, or removes its name if leaf is a tombstone.
+func (n *Node) Apply(leaf *wire.TrieLeaf) *Node {
+	if leaf.IsTombstone() {
+		return n.Set(leaf.NameHash, nil)
+	}
+	return n.Set(leaf.NameHash, leaf)
+}
+
 func (n *Node) get(key crypto.Hash, idx int) *wire.TrieLeaf {
 	if n == nil {
 		return nil
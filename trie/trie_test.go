@@ -3,42 +3,50 @@ package trie
 import (
 	"fmt"
 	"math/rand"
+	"testing"
 
-	"github.com/jellevandenhooff/keytree"
 	"github.com/jellevandenhooff/keytree/crypto"
+	"github.com/jellevandenhooff/keytree/wire"
 )
-import "testing"
 
-func makeEntries(n int) []*keytree.Entry {
-	l := make([]*keytree.Entry, n)
+func makeLeaves(n int) []*wire.TrieLeaf {
+	l := make([]*wire.TrieLeaf, n)
 
 	for i := 0; i < n; i += 1 {
 		name := fmt.Sprintf("%d", rand.Int63())
 
-		entry := &keytree.Entry{
-			Name: name,
+		leaf := &wire.TrieLeaf{
+			NameHash:  crypto.HashString(name),
+			EntryHash: crypto.HashString("entry " + name),
 		}
 
-		l[i] = entry
+		l[i] = leaf
 	}
 
 	return l
 }
 
-func testLookup(t *testing.T, r *Node, k crypto.Hash, e *keytree.Entry, o *keytree.Entry) {
+func leafHash(l *wire.TrieLeaf) crypto.Hash {
+	if l == nil {
+		return crypto.EmptyHash
+	}
+	return l.EntryHash
+}
+
+func testLookup(t *testing.T, r *Node, k crypto.Hash, e *wire.TrieLeaf, o *wire.TrieLeaf) {
 	if r.Get(k) != e {
 		t.Errorf("uh oh get is broken")
 	}
 
 	l, f := r.Lookup(k)
-	if l.CompleteLookup(k, e.Hash()) != r.Hash() {
+	if CompleteLookup(l, k, leafHash(e)) != r.Hash() {
 		t.Errorf("uh oh lookup is broken (bad lookup)")
 	}
 	if f != e {
 		t.Errorf("uh oh lookup is broken (bad entry)")
 	}
 
-	if l.CompleteLookup(k, o.Hash()) != r.Set(k, o).Hash() {
+	if CompleteLookup(l, k, leafHash(o)) != r.Set(k, o).Hash() {
 		t.Errorf("uh oh lookup is broken (bad adjust)")
 	}
 }
@@ -52,20 +60,20 @@ func TestStressTrieRandomly(t *testing.T) {
 		m = 100
 	}
 
-	l := makeEntries(n)
+	l := makeLeaves(n)
 
 	var root *Node
-	rep := make(map[crypto.Hash]*keytree.Entry)
+	rep := make(map[crypto.Hash]*wire.TrieLeaf)
 
 	for i := 0; i < m; i++ {
 		if rand.Intn(2) == 0 {
 			e := l[rand.Intn(len(l))]
-			root = root.Set(e.NameHash(), e)
-			rep[e.NameHash()] = e
+			root = root.Set(e.NameHash, e)
+			rep[e.NameHash] = e
 		} else {
 			e := l[rand.Intn(len(l))]
-			root = root.Set(e.NameHash(), nil)
-			delete(rep, e.NameHash())
+			root = root.Set(e.NameHash, nil)
+			delete(rep, e.NameHash)
 		}
 
 		root.Hash()
@@ -82,14 +90,14 @@ func TestStressTrieRandomly(t *testing.T) {
 		}
 
 		e := l[rand.Intn(len(l))]
-		r := rep[e.NameHash()]
-		var o *keytree.Entry
+		r := rep[e.NameHash]
+		var o *wire.TrieLeaf
 		if r == nil {
 			o = e
 		} else {
 			o = nil
 		}
 
-		testLookup(t, root, e.NameHash(), rep[e.NameHash()], o)
+		testLookup(t, root, e.NameHash, rep[e.NameHash], o)
 	}
 }
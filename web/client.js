@@ -91,6 +91,26 @@ var entryType = {
 
     h.writeUint64(entry.Timestamp);
     h.writeBool(entry.InRecovery);
+    // Only hash the flag for tombstones so existing entries keep their hash.
+    if (entry.Deleted) {
+      h.writeBool(entry.Deleted);
+    }
+
+    // Likewise, only hash validity windows if there are any.
+    var validity = entry.KeyValidity || {};
+    var validityNames = Object.keys(validity);
+    if (validityNames.length > 0) {
+      h.writeString("validity");
+
+      validityNames.sort();
+      h.writeUint64(validityNames.length);
+      for (var name of validityNames) {
+        var w = validity[name] || {};
+        h.writeString(name);
+        h.writeUint64(w.NotBefore || 0);
+        h.writeUint64(w.NotAfter || 0);
+      }
+    }
 
     return h.sum();
   }
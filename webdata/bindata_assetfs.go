@@ -102,7 +102,27 @@ func web_readme_md() (*asset, error) {
 	return a, nil
 }
 
-var _web_crypto_js = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb4\x57\x5d\x6f\xdb\x36\x17\xbe\xcf\xaf\xe0\xab\x8b\x37\x12\xa2\x6a\x49\x9a\x74\xae\xb2\x14\x58\x30\x0c\x1b\x06\x0c\xc3\xb2\x5e\x15\xbd\xa0\xac\x23\x5b\xae\x4d\xa9\x24\x15\xdb\x6b\xfd\xdf\x77\x0e\x49\x89\x94\xed\x24\x5d\x87\x5e\x24\xe2\xc7\x73\x0e\x9f\xf3\x9c\xc3\x0f\x3f\x70\xc9\x7e\xaf\x8b\x62\x09\x92\xdd\x32\x09\x1f\xbb\x5a\x42\x7c\x9a\x7d\x27\xec\x60\xb6\x50\xa7\xc9\xcd\x09\xc1\x04\x9f\x2e\x43\x8c\x5e\x03\x68\x1a\x24\x80\x41\x14\x5c\xc1\xcb\x4b\xc4\x08\x58\xf7\x5e\xe3\x4f\x27\x8c\x95\x5c\xf3\xbb\x5a\xab\x9c\x4d\x52\xec\x4e\x9b\x12\x6c\xf7\x9a\xba\x1f\x60\x7b\xaf\x65\x2d\x66\x39\x3b\x3d\xbf\xb8\x7c\x79\x75\xfd\xea\xfb\xc9\x6b\x5e\x4c\x4b\xa8\x66\xf3\xc5\x87\x95\x68\x3f\x4a\xa5\x1f\xd6\x9b\xed\xdf\xa7\x64\xd0\xf2\x12\xa1\xa6\xc9\xa5\xe4\xdb\x9f\xd0\x7d\xce\xb4\xec\x20\x3d\xd9\xf5\x64\x2a\xd9\xac\xee\x7a\x42\x55\x27\xa6\xba\x6e\x44\xac\x12\x46\x84\x24\xe8\x4e\x0a\xc3\xf3\x6d\x2d\xf4\xe4\x47\x72\x13\x5b\xfe\x59\x09\x44\x10\xa1\xe8\x6a\x67\x9d\xad\x25\x6f\x43\x37\x14\x50\xca\x5a\x09\x23\x77\xd8\x67\x67\x2c\x8a\x23\xfc\xef\x7c\x81\x30\xbe\x08\x9f\xd0\x5c\x12\x0d\x3e\x3b\xf1\x8c\xd7\xba\x62\x66\x28\x53\xcb\x7a\x0a\xf1\xb9\x99\xca\x96\x20\x66\x7a\x9e\xb0\xff\xdd\xde\x9a\x05\x3f\x7f\x66\x01\xc8\x23\x42\x34\xae\x7c\x61\x2d\x88\xdc\xd8\xc2\x34\x1d\xec\xc5\x00\x4b\x22\xcb\x81\x31\x3d\x97\xcd\x9a\x45\xbf\x62\x20\x52\xc2\x54\x2f\xb7\xac\x6a\xe4\x8a\x6b\x0d\x25\x53\x26\x6f\x18\x13\x63\x3b\xaf\x83\x97\x3e\x3e\x4a\x8d\xe8\xa4\x6c\x7f\x61\xaf\xf6\xb4\x11\x53\xae\x4d\x4e\x54\xa8\x0f\x8a\x53\x58\x5a\x84\xd2\xab\xd6\x95\x5a\x90\x42\xee\x97\x28\x7a\xa9\x88\x1d\x82\x33\x05\x9a\x5c\x9c\x8f\x06\x8a\x94\xf1\x10\xe8\x42\xc0\xe9\x81\xce\x0c\x04\x48\xae\xe1\x4f\x2e\xca\x66\x75\x5f\xcf\x04\xc6\xfc\x1b\x6c\x5b\x5e\xcb\x90\x9e\xa7\xf6\xa9\xed\x0a\x8c\x19\x31\x29\x53\x30\x45\x9f\xd8\xdc\x11\x5b\xdc\x2e\x99\x42\x0f\x19\x16\xfd\x1f\x68\x1f\x87\x8b\x5a\xc1\x07\xdb\xdc\x94\x5d\x1c\xf8\x8a\xa0\xbc\xbc\xbe\xbe\x78\xfd\x02\xc7\xa2\x24\xb5\x70\x59\x3f\x20\x39\x8f\x1f\x16\x0c\xf1\x08\x8a\x12\x93\xa5\xa3\x41\xdd\x35\x9b\xff\x10\x50\xd1\x6c\xbe\x32\x1e\xb4\xfc\xe2\x58\x0c\xf6\x20\x0e\x12\x33\xe4\xec\x5d\xa4\x66\x8e\xe3\x21\x14\xd4\x4c\x73\x6f\xf1\x61\x89\xc5\x3d\x2e\x9b\x73\x35\x8f\x93\xd4\x46\xd5\xe9\x7a\xe9\xce\x82\xb7\x7f\xfd\x3c\xf1\x28\xbd\x6d\x21\x4e\x12\x13\x29\x39\xa5\x25\xd1\xa5\xdd\xcf\x23\x02\x7b\xfa\xf7\x06\xe8\x69\x54\x0b\x25\x68\x3e\x9d\x43\x19\x5b\x7a\xa9\xf1\x18\x0a\x69\xb5\xa8\x67\x81\x47\xec\x45\x7e\xc7\x3c\x80\xac\xab\xed\x48\x87\x20\x61\x8e\xb8\x6b\xa1\xc7\x6f\xae\x48\x57\x04\x82\x3c\x56\xbf\x63\x39\x1c\x7a\xa0\x78\x18\xab\x3f\xb6\x0f\x94\xcb\xac\x00\x83\x80\x46\x2b\x5c\xc4\x2b\x84\x07\xb1\xdc\xb6\x3a\x94\x68\x05\x4a\xf1\x19\xa4\x2c\x60\xe8\xb3\xe7\x25\x12\xa8\x0c\xf4\x09\x93\x76\xbf\x6c\x35\xa8\xf8\xf2\xea\xf9\x88\x87\x0a\xff\x82\x6a\xf1\x15\xde\x83\x1d\x6b\x3c\x69\xfd\x56\x8b\x8f\xe6\xc2\x05\x43\xa9\x22\xba\x26\xa8\xbe\x90\xd8\x5e\x25\x8d\x52\xed\xe0\xc3\x4a\x89\x23\x82\x7f\xa6\xc0\xdc\x75\x8a\x4b\x7d\xad\x7e\x2b\x15\xe4\x77\x30\x0a\x17\xd9\xd3\x79\x7c\xa4\xa3\x79\x56\x74\x55\x05\x12\xcf\xee\x94\x05\xa2\x8f\xd4\x79\xdc\xe8\x5b\xa6\xc9\xa1\x25\xa8\x6e\xa9\xc3\xf3\xb0\x69\x41\xc4\x03\xc1\x63\x59\x71\x37\x7c\x6f\x8a\xb7\x6e\xc5\x97\x0a\xf6\xee\x5d\xa7\x3b\xea\x8d\xd3\xf5\x12\x4a\x77\xd9\xee\xed\x06\x53\x0e\xf6\xb1\x61\xca\xc1\x7a\x0d\xf2\xf7\x0b\x6e\x64\x38\x72\xba\xeb\x79\xad\xb2\x96\x4b\x4d\x37\xed\xbb\xf7\xd6\xc0\x82\xb3\x56\x36\xba\xa1\xbd\x9d\xad\x65\xad\x21\x34\xb6\xda\xee\xbb\xc8\xda\x0e\x8f\x0b\x37\xf7\x94\x2b\xca\xd4\xab\xab\xd0\xa1\xf0\xf5\x62\xed\x0f\x73\x3a\x31\x9a\xe1\xe3\x83\xc5\x04\xab\x11\x71\x7e\x83\x9f\x1f\xd8\x04\x3f\x67\x67\xbd\x72\xd6\xfe\x5d\xfd\x9e\x5c\xb0\xff\xb3\xf3\x4d\x55\xdd\x98\x19\x61\x46\xde\xbc\x41\x03\xf7\x62\x31\xdc\x0d\xa5\x67\x68\xab\x6e\x75\xfc\x6a\x74\xef\x0d\xe2\x12\xb2\x83\x25\xac\x58\x53\x05\xe2\xf4\xf4\xfa\x07\xca\xad\xc1\xb8\xb7\x47\xcf\xe7\xa9\xf8\x83\x57\x8a\x89\x5f\x94\xb0\xf9\x17\xeb\x5a\xb7\xe6\xd1\x43\xa0\xd4\x3a\x48\xac\x32\xd6\xd9\x71\x4e\x61\x9d\x99\xfb\x60\xa4\x14\x2d\x4a\xa3\xf6\x09\x7f\xe4\xad\x6d\x00\x2e\x1a\x2b\xab\x7d\x24\xcc\x9d\xee\xc7\xaf\x96\x24\x3c\xf0\xe7\x24\x7f\xec\x0f\x73\xdd\xe0\xcf\x87\xc3\x05\xc3\xa2\x34\x17\x0b\x4e\x46\xd1\x63\x45\xe3\xf4\x70\xb1\x86\x05\x34\xa0\x17\x16\xbd\xb0\x25\xb6\xf0\x08\x86\xbe\x51\xac\xd8\x97\x1a\x16\xd5\x22\xc1\x62\xbb\xb0\x7a\xee\xc6\xda\x29\x43\x7d\xd5\x94\x1d\xde\x96\xb0\x69\x1b\xbb\xdf\xc8\xd9\x53\xaf\xcb\xfc\xc9\xd9\xf4\xc0\xda\x3f\xe3\xf6\x2d\xfd\x0c\x59\xd1\xdd\x99\x9b\xff\xd4\xb3\x77\x67\xee\xbe\x34\xe2\xce\xad\xbc\x6f\xd0\x98\x3b\x88\xf2\xbe\x41\x63\x36\x9d\xb9\xfb\xd2\x88\xaf\x84\x3c\x68\xd3\x4c\x90\xb3\x3c\xec\xd0\x9c\xff\xc1\x90\x07\x6d\x2a\xaf\x7f\x02\x00\x00\xff\xff\x96\x0f\x05\xdb\xa0\x0e\x00\x00")
+var _web_client_js = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9d\x58\x6d\x6f\x1b\x37\x0c\xfe\x9e\x5f\xa1\x1a\x45\x63\xa3\xce\xf9\x25\x2f\x73\xec\xa6\xc5\xb2\x14\x5b\xb7\xa2\x19\xd2\x6c\xfb\x10\x04\xa8\x72\xc7\xf3\xc9\x3e\x4b\xb6\xa4\xb3\x7d\x49\xfd\xdf\x47\x49\xf7\x6a\x3b\xe9\xb0\x0f\x41\x2e\x12\xf9\x90\x7c\x48\x51\x54\x96\x54\x12\x5f\xa6\x73\x2d\xc8\x05\x91\xb0\x48\x98\x84\xe6\xa1\xd7\x71\x6b\x87\xad\xd1\xc1\xc1\x12\x45\x5e\x57\x77\x27\x8b\x04\x64\x5a\xec\x4d\xb9\x58\xf1\x3f\x20\x55\x28\xf3\x74\x40\x48\x63\x0a\xa9\x96\x00\x1e\x13\x8d\x21\x69\x40\xd0\x3f\x3d\xed\x9d\x1f\xcd\x93\x87\x66\xff\x6c\x35\x39\xed\xf7\xb9\x9f\xce\xe5\xd4\xef\xea\xf3\x54\xf6\xa0\xe7\x3f\xf6\xd5\x23\xcc\xe6\x0f\x41\x30\xa5\x69\xb7\xbf\x58\x2c\xd6\x8b\xe9\x84\x4f\x1f\x78\x3a\x1e\xb7\x1a\x6d\x03\x3a\x7b\x04\x29\x3c\x21\xc7\xdb\x98\xa7\xc7\xe9\xe0\x24\xf4\x07\xd4\x0f\x06\x8f\x3d\xdd\xf5\xa7\xab\xa5\xf6\x7b\xdc\xef\x2b\x29\xc7\x72\x0a\x70\x3a\x5b\xe9\xf5\x52\x07\x8b\x54\xcf\x57\xd2\x3f\x3e\xcb\x31\x75\x04\x6a\x91\x50\x09\xf3\x98\x72\xd0\x9e\x2f\x66\xdb\xe8\xe7\x52\x76\x07\x30\x88\xc2\x41\x7f\x1d\x4e\xe7\xeb\xf3\x93\x93\x75\xa4\x4f\xa8\x9a\x2a\xaa\xc2\x31\x5f\x4f\x06\xe1\x7a\x3a\x0b\x8f\xb5\xff\x08\x74\xd2\x5b\xfe\xb4\xe8\x1a\xf4\x4d\x46\x0e\x72\x83\xb4\x84\x09\xf7\x35\x13\xbc\xd9\xb2\x0c\x49\xd0\x89\xe4\xe4\x8a\x6a\xf0\x50\x00\x57\x3b\xa4\xd7\xed\x76\x47\x85\xda\x8c\xae\xbf\xb2\x31\xa7\x28\x07\x3f\x8f\x01\x21\xce\xba\x23\xd2\xe9\x10\x1a\xc7\x88\xa8\xf2\x3d\x45\x92\x39\xc1\xdc\x9d\x75\x89\x02\x5f\xf0\x40\x11\x11\x07\x0e\x23\x80\x90\x26\xb1\xfe\x45\xf0\x90\x8d\xb3\xdc\x60\x6a\xd4\x90\xdc\x15\x29\xbb\xab\x26\xeb\xbe\x4d\x2a\x1b\x25\xe1\xf5\xf5\x7d\xa4\xdd\xdf\x1b\x3e\x75\x84\x0e\x45\x68\x7f\x48\xfa\x45\x24\x01\x2a\xc6\x82\x06\xbf\x2b\xc1\xab\x4c\x24\x32\xae\x91\xf1\xa7\x14\x33\xa6\xc0\x43\x08\x11\x2f\xa1\xf9\xda\xa3\x13\xba\x36\x62\x6d\xf2\x14\x50\x4d\x6f\xd3\x39\x0c\xc9\xe1\x04\x71\x0e\x37\x2d\x2c\xbe\x8d\x33\x80\xf6\xe7\x31\x68\xf8\x2c\xc4\x14\xc9\xa8\x98\x88\xed\x4a\xdb\x04\xdd\x26\x4b\x1a\x27\xe0\x2c\x5a\xad\x44\x4a\xe0\x7a\x94\xfd\xc9\xd4\x67\xa0\x21\xba\x4c\x08\x0b\x49\xd3\x55\xbf\x17\x51\x15\x7d\x5c\x34\xad\x6a\x3b\x3b\x26\x1e\x16\xaa\x4e\x7f\xc3\x9d\x96\x43\x23\x39\x16\x9a\xde\x16\x19\xd9\x7d\x07\x6e\x3c\xa3\xb1\x02\xb3\xb6\x21\x80\x5f\xcf\xa9\x63\x44\x0f\x8c\x83\x01\x00\xd5\xac\x78\xbf\x05\xa7\x65\xe2\xd0\x0e\xb2\x28\x62\x5c\xff\x14\xac\x4b\xa4\x90\x49\xa5\xaf\x58\x18\x02\x5a\xf0\xc1\x61\xe5\x7b\x48\xf8\x25\x55\x70\xdc\xcf\x88\xf2\x0c\x2c\xe6\xd8\x70\x8b\x80\xa1\x90\xa4\x69\xb9\x29\xf1\x0c\x21\x97\x4c\x2b\x72\x44\x7a\x23\xdc\x78\x7f\x41\xb0\x2c\xd9\xd1\x51\x4e\x85\x91\xcf\xa3\x46\x1e\x33\x60\x17\xc9\x1d\xbb\xcf\xc5\x08\x89\x2a\x4e\xee\x38\x52\xca\x3b\xa8\x1a\x5d\x35\xdd\x2d\xa6\x2d\x13\xce\x34\x7a\x7d\x91\x13\xb2\xd7\x6c\x9d\xe5\x1f\x92\xd2\x26\x91\xe3\x25\x37\x50\xaf\x91\x2c\x89\x2f\x54\x49\x3d\xd5\x99\xc3\x7b\xf3\x99\xc9\x0a\xae\x19\x2f\x57\x36\x64\x3b\xc4\xba\x07\xd1\x1e\xdb\xe4\xcd\x9b\x0c\xbe\xf4\xa2\x8e\xbb\x0b\x37\x06\x8d\x29\x76\xa5\xc2\x5a\x86\xc5\x6e\x45\xf9\xe5\x5a\x2d\x58\x88\xf6\x67\xee\x07\xea\x26\x04\x27\x91\xab\x3f\x77\x7e\xca\xbe\x51\x1c\xe4\xac\x1d\xe0\xb7\x4c\x4d\xaf\xc8\x3a\x9e\xb6\x6d\xa3\x31\x66\x3a\x4a\x1e\x8c\xbd\xce\x04\xe2\x18\x96\x94\x07\xc0\x23\x21\xc2\xb0\x93\xb7\xc0\x8f\x46\xf5\xa8\xeb\x9d\xd8\xdb\xc1\xd0\x3a\x2c\x9b\x89\xc5\xcd\x89\x30\x74\xd9\x05\x43\x0f\x4f\xe2\xb8\x64\x28\x77\xeb\xa5\xfa\xb4\xa7\x04\xfd\xe3\xb0\xca\x05\x2d\x03\xb2\x99\xc5\x1d\x79\x2b\xc9\x34\x7c\xd5\x92\xf1\xb1\xb3\xe4\x7d\xa1\x33\xc8\x2b\xd0\xde\x2c\xf8\xb7\xb9\x72\xaf\x1f\x26\xe0\x6b\xcf\x74\xf6\x4c\xd2\xf4\xe9\x0c\xc8\x0a\x79\x4a\x48\x8d\xd0\xe6\xfe\xb8\xbd\xbe\xba\x1e\x92\x4f\x0a\x9b\x35\x53\xc4\x6c\x10\x21\x03\x30\x76\x70\x09\x88\x42\x05\x42\x15\xf9\x55\x1c\xaa\x0f\x07\x55\x67\xfe\x62\x5c\x9f\x9d\x34\x1d\x62\x0c\x7c\xac\xf3\x24\x17\x9d\xc2\xec\x11\x11\x3a\xab\x25\x25\x76\x48\x00\xe4\x8a\x94\xfe\xdd\x19\x99\xfb\xbc\xb6\xeb\xf1\x72\x17\xe9\xbe\x2d\x84\x69\xd5\xa8\xac\x3b\xe7\xf0\x6f\x19\x9a\xd7\x74\x36\xaf\xb3\x79\x29\x44\x9c\x49\x7c\xe2\x37\x78\x5b\x2e\x71\x8a\xc9\x44\x90\x99\x6b\x1e\xa7\x36\xe9\x96\x87\x30\xa6\x63\x1b\x98\xc6\x0a\x55\x5a\x70\x30\x6c\x11\x58\x33\xa5\x0d\x57\x06\x87\xe1\x1a\x8e\x17\x73\xa3\xc0\xa4\xd5\xf5\xea\xe5\xe1\x5d\x81\xb9\x9a\x82\x4a\x03\xda\xf1\x25\x17\xa9\x85\x85\xfe\x7c\x66\x53\x58\xe1\x8d\xd8\x26\xa2\xf0\x0c\xaf\x01\x16\x30\x9d\x92\x15\xe3\x78\xb1\x2a\x63\x09\x8d\x4b\x4c\x99\xf9\xe1\xa9\x57\x94\x47\x21\x5a\x61\xfd\xef\x7c\xed\xfb\x77\xf2\xb4\x19\xed\xc8\x7e\xd9\x53\x52\xf9\x5e\xab\xec\xea\x35\xf1\xac\x14\xc8\xfb\x6a\x9b\xa8\x27\xad\x91\x2b\x34\xca\x0e\x5a\xc7\xc8\x0a\xb4\xae\x9d\xe5\x74\x9f\xb5\x42\x74\xa7\xf4\x6a\xd2\xd5\xd6\x6b\x84\xcc\x28\x96\x0b\xb8\x02\xac\x32\xf1\x83\x42\xdc\xf6\x6b\xe5\x7d\x11\xfa\x12\xd0\x03\x30\x28\xdd\x97\x05\x7f\x0e\x35\xc8\xba\xdc\xa6\x9a\xf0\xac\x6d\x44\x9e\x4a\x66\x8e\x89\x4d\x31\x42\x49\x21\xf4\xff\xeb\x68\x37\xa8\x89\x0d\xad\xb7\xaf\xa1\x19\xd4\xda\xb5\xfd\x1f\x1a\xd2\x9e\x6b\xd2\xc0\x58\x3b\xee\xb2\x1b\xed\x39\x95\x56\x64\xfb\x50\xbe\x14\xb0\xbb\x7a\x6f\xa8\xc9\x17\x55\x29\xf7\x4b\xaf\x6d\x4a\x8a\x29\x2e\xce\x67\x3e\xba\xa2\x4c\xd7\x66\xcd\xe6\xb7\x48\xeb\xf9\xb0\xd3\x29\x07\xdc\xfc\xb3\xe3\xd4\x3e\x18\xac\x8b\xd7\x4f\xe6\xd7\xe6\x9b\x2b\x4d\x37\x1a\x6a\xe4\xd0\xb0\x61\x47\xf3\xa3\xed\x61\xbc\x3e\x1d\x99\xc6\x86\x75\x57\x3d\x32\xd9\xe4\x60\x74\x20\xb8\xc5\x36\xe1\x46\x53\xd5\xaa\xd2\xad\xec\x6e\x31\xb4\x3e\xa7\x73\x87\x88\xf7\xa3\x2d\x2d\x43\x37\xea\x54\x21\x32\x4d\xb3\x53\xb9\x23\x60\x3d\x47\xaf\xdc\xb2\xc9\x8f\xb9\x78\x6b\xe3\x72\xb3\x86\x51\xda\x6d\x57\x27\xbe\xea\x69\x68\x97\x17\xac\xdd\xcb\x83\xb5\x77\x67\x2b\x3f\xdf\xa6\x4b\xbc\xda\x1a\x90\x76\x2a\xa7\x8c\xc5\xbb\xa9\x15\x51\x7b\xc7\xef\xca\x10\x85\x8f\x0c\x7c\x00\x35\x1e\x68\x60\xcf\x85\xad\xea\x46\x75\x5a\x28\x1c\xd8\xc6\x2f\x2a\x90\xbc\xcb\x72\xbc\x83\x5a\xbc\xab\xb0\xed\x0b\xf3\x92\x6a\x3c\x37\x25\xe1\xdd\xc1\xc2\xd4\x4d\x49\x5b\x86\xda\xc5\x81\xad\x6d\x15\x25\xd4\x22\xaf\x2e\xdc\xb0\xb7\x37\xaa\xc2\x87\x4a\x54\x1b\xcb\x6b\x76\x64\x1c\xe3\xa3\xad\xd3\xf2\x0f\xb6\x83\xe2\xa9\xb7\xe7\xd0\xb4\xcd\xe0\x87\xbb\xcf\x1e\x9e\xe2\xd0\xe5\x6d\x2f\x13\x13\x53\x62\xa6\xfc\x3d\x35\xef\x00\x6d\xcd\x57\x07\x23\xb3\xcb\xf8\xb3\x15\x5d\x06\x2d\xa6\x6f\xdf\x56\x62\x3c\xb0\xb9\x33\x08\x68\xf2\x5d\x8e\x5e\x3c\x2a\x73\xbd\x8c\x2a\x8e\xb9\x07\x2e\x92\x71\x54\x79\x0d\x37\x8a\xe7\x50\x8d\x2b\x57\x9d\xdb\x8c\xbd\xd4\x5c\x32\xf5\x2a\x33\x25\xc1\x19\x9f\xb5\xf7\xb5\x7b\x8f\xce\x44\x90\xc4\xe0\x61\xf9\xe2\x85\x96\xff\x3f\xa4\x20\x76\x58\x7e\xb6\x8b\xf5\x12\x76\xb8\xb3\x52\x4a\xe5\x7b\x26\x84\x7f\x01\xcd\xc0\x46\xed\xb3\x11\x00\x00")
+
+func web_client_js_bytes() ([]byte, error) {
+	return bindata_read(
+		_web_client_js,
+		"web/client.js",
+	)
+}
+
+func web_client_js() (*asset, error) {
+	bytes, err := web_client_js_bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindata_file_info{name: "web/client.js", size: 4531, mode: os.FileMode(420), modTime: time.Unix(1792324800, 0)}
+	a := &asset{bytes: bytes, info:  info}
+	return a, nil
+}
+
+var _web_crypto_js = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xbd\x58\x5b\x73\xd4\x36\x14\x7e\xcf\xaf\x50\x3d\x53\x62\x17\x63\xb2\x21\x81\xc5\x21\x74\xc8\x40\xa7\xa5\x33\xb4\xd3\x94\xa7\x4c\x1e\xe4\xb5\xbc\x2b\x58\x5f\x22\xcb\xd9\xdd\x42\xfe\x7b\xcf\xd1\xc5\x92\xf7\x46\x80\x69\x1f\x92\x95\x8e\xce\xf5\x3b\x47\xd2\x91\x6f\xa9\x20\xef\x78\x96\xcd\x99\x20\xe7\x44\xb0\x9b\x8e\x0b\x16\x1e\x26\x8f\x2b\x4d\x4c\x3e\xb4\x87\xd1\xd9\xc1\x2d\xb0\x55\x74\x32\xf7\x79\xe4\x82\x31\x89\x44\x64\x50\x1c\x19\x6d\xd9\x93\x63\xe0\xa9\xd8\xc2\x6a\x0d\x3f\x1d\x10\x92\x53\x49\x2f\xb8\x6c\x53\x32\x8e\x61\x3a\xa9\x73\xa6\xa7\xa7\x38\xfd\xc8\x56\x97\x52\xf0\x6a\x9a\x92\xc3\xa3\xd1\xf1\x93\x93\xd3\xa7\xcf\xc6\xcf\x69\x36\xc9\x59\x31\x9d\x7d\xf8\x58\x56\xcd\x8d\x68\xe5\xed\x62\xb9\xfa\xe7\x10\x05\x1a\x9a\x03\xab\x1a\x52\x21\xe8\xea\x35\xa8\x4f\x89\x14\x1d\x8b\x0f\xee\xac\x33\x85\xa8\xcb\x0b\xeb\x50\xd1\x55\x13\xc9\xeb\x2a\x6c\x23\x82\x0e\x09\x26\x3b\x51\x29\x3f\xdf\xf3\x4a\x8e\x5f\xa1\x9a\x50\xfb\x9f\xe4\x0c\x1d\x04\x56\x50\x75\x67\xb4\xc9\x7a\x53\x57\x36\xd0\x65\x84\x59\xa5\x84\x33\x4f\x76\x46\xdb\x19\x86\x0b\xb2\xa7\xa3\xe3\x33\x47\x5b\x49\x86\xc4\x7e\xfd\x31\x19\x1b\x11\x56\x36\x72\xf5\x2b\xd0\x0d\x98\x9e\x93\xbd\x24\x06\xda\xeb\x7a\x73\xe3\x7b\x46\x63\x62\x9c\x2b\x6a\x41\x42\x64\xe2\xb0\x7e\x74\x06\x3f\x2f\x9c\x6d\x98\x3e\x7c\xa8\xf9\x08\xe1\x05\x09\xe9\x15\xbf\x26\x3f\x9c\x93\x0c\x7e\x2d\xbd\x0f\xb0\xa0\xf3\x96\x9d\x29\xda\xdd\x81\xfe\x33\x2b\x88\xbc\x0b\x77\x21\x68\xe3\x3b\x83\xc9\x8f\x49\x23\xd8\x00\x2e\x98\x93\x87\x24\x08\x03\xf8\x6f\xc1\x55\xac\x11\x92\xa3\xc0\xe9\xeb\xaa\x2f\x68\x44\xcf\x91\x94\xb4\x73\x3e\x61\xe1\x91\x5a\x4a\xe6\xac\x9a\xca\x59\x04\xe1\x9c\x2b\x63\x9f\x3f\x13\x8f\xc9\x71\xf8\xdc\x60\x7a\xa4\x25\xd0\xb1\xa1\x84\x1a\x1a\xb6\x47\x3d\x5b\x14\x58\x9c\xe4\x4c\xd4\x0b\x12\xfc\x06\xf9\x17\x82\x4d\xe4\x7c\x85\xe0\x97\x54\x4a\x96\x93\x56\xd5\x77\x70\x36\xc4\xcd\x95\x68\xb8\xd5\x35\x74\x27\x26\xeb\x86\xbd\xca\x9a\xd4\xd5\x84\x4a\x55\x17\xed\xf6\xfc\xab\xda\x2d\x9b\xcd\x32\xa2\xce\x46\x66\xb1\x42\xf7\x80\x39\x69\x99\x44\x15\x47\x03\x42\x16\x13\xea\x33\xda\xdc\x97\x8d\xf3\x67\xca\x2a\x26\xa8\x64\x7f\xd1\x2a\xaf\xcb\x4b\x3e\xad\x20\xea\xdf\xd9\xaa\xa1\x5c\xf8\xfe\x39\xdf\x3e\x35\x5d\x06\x51\x03\x4f\x4c\x5a\x36\x01\xa5\x30\xbc\x43\x77\xe1\x60\x49\x5a\xd0\x90\xc0\xf1\xf0\x27\xc8\x87\xbe\x55\x0d\x79\x2f\x9b\xaa\xa2\x0b\x3d\x5d\x01\xcb\x8f\x4f\x4f\x47\xcf\x1f\x01\x2d\x88\x62\xcd\x2e\xf8\x2d\x38\xe7\xf8\x7b\x83\x3e\x3f\x30\x05\x91\xca\xd3\xf6\xa8\x2e\xea\xe5\x77\x44\x94\xd5\xcb\x6f\x0c\x08\x24\xef\x1d\x8c\xe2\xdd\x0c\x04\xe1\xf4\x9d\x76\x3a\x62\xc2\x25\x2b\x63\xc5\x41\xe1\xd8\xf6\x8a\xa7\xbe\xd4\x52\x7e\xad\x85\x96\x2f\xc1\xb3\x24\x44\xd9\x28\xd6\x01\x76\x92\xcf\xcd\x09\xfa\xfe\xef\x5f\xc6\x8e\x53\xae\x1a\x16\xa9\x90\x51\x2d\x9a\x06\xa5\x7a\x6f\x0f\x1c\x59\xcb\x84\x15\x00\x3d\x83\xaa\xc8\x99\xa4\x93\x19\xcb\x43\xed\x60\xac\x34\xfa\x88\x6a\x50\xf8\xd4\xd3\x08\xb3\xc0\xdb\x3d\xb7\x4c\xf0\x62\x35\x00\xc4\xe1\x3d\xc4\xc3\x8c\x40\xf1\xff\x00\x4d\x97\x79\xc8\xec\x2a\xe9\x21\x2e\x86\xbb\x77\x72\x33\x68\x77\xe7\x6d\x40\x98\x68\x20\x7a\x24\x15\x68\x60\xc4\x83\x0a\x6e\x35\xb1\x6a\xa4\x8f\x55\xc9\xda\x96\x4e\x59\x4c\x3c\x17\x5d\x1e\x1d\x4a\x15\x80\xc3\x6c\xea\x84\xde\x42\x78\xf9\x84\xc7\x27\x5f\x0e\xb9\x2f\xfa\x7b\xd4\x8d\x2b\x7a\xcb\x6c\xbc\x86\xf3\xd7\xed\xbe\x70\x6b\x2a\x4c\x30\x98\x29\x74\x57\x05\x65\x4b\x8a\xac\xd5\xd4\x20\xdb\x86\xbd\xb7\x14\x19\x47\xe0\x2f\xd8\xc4\xef\x6d\x5b\x57\xdf\x82\xe1\x07\x2d\xf7\xf6\xf2\x8f\x77\x89\xbe\x4a\x30\x5f\xd6\x69\x2f\xb9\xc6\x4e\x88\x02\xbb\xb4\x3a\xaf\x00\x80\x6f\xcd\x6a\xd9\x7a\x65\xd7\x0b\xf9\xa1\xaf\x65\x7f\x78\xfb\x80\x78\x92\x75\x45\xc1\x04\x5c\x33\x31\xf1\x4a\x61\x90\xb3\xdd\x42\xff\x65\xf1\x18\x6e\xc1\xda\x6e\x2e\xfd\x83\xbb\x6e\x58\x15\xf6\x0e\x6e\xab\x15\xd3\x8d\x58\x51\xe8\x10\x54\xd7\xb4\xd6\x23\x18\xdc\x01\x6f\x58\xe6\x73\x96\x9b\xc6\x60\x6d\x93\xaa\x22\xd5\xfd\xa4\x2a\x52\xad\x75\x33\x7f\xdf\x59\x55\x46\xcb\x97\xc4\xbc\x2a\x53\x75\xd8\x50\xd1\x32\x55\x68\x9e\x47\xd8\xb2\xb2\x2d\x17\xa3\x9c\xf1\x16\x25\x54\x13\x7c\x75\xad\x05\x34\x73\xd2\x88\x5a\xd6\x78\x04\x26\x0b\x01\xc7\xe5\xa0\xc3\x56\xd9\x5e\x57\x91\x34\x1d\x9c\xac\x66\x6d\x9f\x2a\xac\x9d\xa7\x27\xbe\xc2\xca\x45\xaf\xe5\x37\xab\x6c\xac\x22\xdd\xd6\x37\x8f\x07\xfd\xb2\x96\xc7\x86\x19\x54\x90\x07\xe4\x68\x59\x14\xba\x3b\xae\x14\xe5\xe5\x4b\xec\xe6\x75\xbf\xb7\x4d\xdb\xc9\x40\xdb\x55\xaf\x2e\xb6\x9a\x9f\x41\xb7\xc7\xaf\x51\xfd\xd5\x80\x12\x3b\xd3\xd7\xd6\x80\x02\x47\xc5\x7c\x1f\x5c\xf4\x6b\x6b\xcb\xb3\x48\xb5\xdb\xb2\x18\xdb\xa2\xdf\xb8\xb1\x74\x47\xd8\x1b\xd3\x00\x87\x28\x32\xe8\x21\x9d\x37\xb8\xb4\xd7\x97\x8b\xba\x9e\x6f\x79\x54\xed\xcb\xd0\x48\x19\x31\x20\x1c\x21\x40\x19\xf9\x99\x8c\x48\x0a\xd0\x7e\x0d\x16\x6d\x57\x6e\x6f\xe1\x4c\x67\x7c\xae\xf5\xf5\xc9\x63\x73\x56\x92\xba\xf0\x2a\xd1\x66\xcf\xb6\xd2\xe7\x8a\xc7\x40\x61\x73\xb3\x2f\x14\x0f\x34\x55\x1e\x55\xce\x96\x5f\x61\x57\xab\x55\xed\x39\x32\xc5\x5a\x41\xa4\xcb\x50\x2b\xdb\xee\x93\x7f\xcc\xa8\x3e\x65\x80\x94\x7d\x54\xee\xad\x13\xfb\x34\xd5\xb0\xea\x66\x76\x66\x70\xdf\x51\x3c\xfe\x19\x32\x43\xf8\xc3\xe1\x0b\x9b\xcb\x4d\x8b\xfe\x11\xa0\xfa\x1d\x58\x0c\x82\x5d\x5b\xd4\x00\x62\xf2\xf1\xd3\xda\x96\x6d\x11\x8d\x29\x93\x60\x28\xb4\x17\x09\x8f\xd6\x30\x69\xfd\xe7\x55\x99\xf1\x8a\xa9\x08\x77\xbc\xaf\xf0\xb4\xd7\xef\x6f\x24\xf6\xcf\xf6\x88\x3c\x78\x60\xde\xe5\xf8\x70\x72\x74\xeb\x8a\xbd\xaf\xed\x82\x5f\x2c\xfb\x90\xa5\x83\x59\xb6\x17\x51\x1d\xa9\xef\x37\x7a\x04\x21\xe7\xcb\xc1\x53\x5c\x91\xaf\x42\x20\xe3\x17\x88\xe8\x33\x6c\x29\x38\xbc\xd4\xfc\x47\x98\x43\x2c\x64\x04\x5a\xcd\x67\x15\x2e\x5a\xf9\x9a\x23\x78\x4c\x5f\xed\x3b\x5e\x9d\x28\x6e\x2a\x79\x31\x83\x4b\x4e\x2b\x7c\xe1\xbe\x76\x00\x42\x26\x17\xd4\xf8\x74\xee\xb2\xa3\x29\xfd\xb7\x89\x5c\x15\xf2\x68\x2d\x55\x40\x56\x7e\x95\x75\xde\x41\x1b\xcd\x96\x4d\xad\x6f\x18\x94\xda\xf7\x16\x4d\xf7\xae\xc6\x1b\xd2\xee\xcd\x97\xee\x5c\x41\x29\x6c\xab\x53\xf5\x1f\x67\xba\xad\x4e\xcd\x2f\x52\x4c\xef\x90\xda\x81\x47\xc3\x4b\x3c\xf5\x27\xb8\x66\xae\xe6\xd4\x0e\x3c\x9a\xe6\xf7\x26\xb8\xa6\x2b\x26\x35\xbf\x48\x71\xdb\x38\xf5\xc6\xb8\xe2\xed\xb7\xd4\x9f\xe0\x9a\xfb\x30\x91\x7a\x63\x23\x65\xe8\x76\x64\xad\xe8\x0f\x7a\x76\xa4\x22\xb3\xc5\x9d\xba\xa1\xe5\x7e\x73\x93\x9a\x5f\x8d\x36\x66\x3d\x35\xbf\xca\x83\x61\x99\xa5\xeb\x04\xfd\x19\xd1\xdb\x9e\xe9\x70\x8a\x9b\xe0\x5f\xbc\x19\x29\xfc\xdc\x14\x00\x00")
 
 func web_crypto_js_bytes() ([]byte, error) {
 	return bindata_read(
@@ -117,7 +137,7 @@ func web_crypto_js() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "web/crypto.js", size: 3744, mode: os.FileMode(420), modTime: time.Unix(1428445279, 0)}
+	info := bindata_file_info{name: "web/crypto.js", size: 5340, mode: os.FileMode(420), modTime: time.Unix(1792324800, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -436,6 +456,7 @@ func AssetNames() []string {
 var _bindata = map[string]func() (*asset, error){
 	"web/.gitignore": web_gitignore,
 	"web/README.md": web_readme_md,
+	"web/client.js": web_client_js,
 	"web/crypto.js": web_crypto_js,
 	"web/index.html": web_index_html,
 	"web/keytree.js": web_keytree_js,
@@ -497,6 +518,8 @@ var _bintree = &_bintree_t{nil, map[string]*_bintree_t{
 		}},
 		"README.md": &_bintree_t{web_readme_md, map[string]*_bintree_t{
 		}},
+		"client.js": &_bintree_t{web_client_js, map[string]*_bintree_t{
+		}},
 		"crypto.js": &_bintree_t{web_crypto_js, map[string]*_bintree_t{
 		}},
 		"dist": &_bintree_t{nil, map[string]*_bintree_t{
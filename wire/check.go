@@ -6,6 +6,11 @@ func (e *Entry) Check() error {
 	if e == nil {
 		return errors.New("missing entry")
 	}
+	for _, validity := range e.KeyValidity {
+		if validity == nil {
+			return errors.New("missing key validity")
+		}
+	}
 	return nil
 }
 
@@ -162,3 +167,118 @@ func (req *DKIMStatus) Check() error {
 
 	return nil
 }
+
+func (k *DKIMKey) Check() error {
+	if k == nil {
+		return errors.New("missing dkim key")
+	}
+
+	return nil
+}
+
+func (k *SignedDKIMKey) Check() error {
+	if k == nil {
+		return errors.New("missing signed dkim key")
+	}
+
+	if err := k.Key.Check(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *DKIMProof) Check() error {
+	if p == nil {
+		return errors.New("missing dkim proof")
+	}
+
+	// Proofs from before keys were archived carry no key.
+	if p.Key != nil {
+		if err := p.Key.Check(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *SMIMEProof) Check() error {
+	if p == nil {
+		return errors.New("missing s/mime proof")
+	}
+
+	return nil
+}
+
+func (s *HTTPSStatement) Check() error {
+	if s == nil {
+		return errors.New("missing https statement")
+	}
+
+	return nil
+}
+
+func (a *HTTPSAttestation) Check() error {
+	if a == nil {
+		return errors.New("missing https attestation")
+	}
+
+	if err := a.Statement.Check(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *SignedHTTPSAttestation) Check() error {
+	if a == nil {
+		return errors.New("missing signed https attestation")
+	}
+
+	if err := a.Attestation.Check(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *DomainStatement) Check() error {
+	if s == nil {
+		return errors.New("missing domain statement")
+	}
+
+	return nil
+}
+
+func (a *DomainAttestation) Check() error {
+	if a == nil {
+		return errors.New("missing domain attestation")
+	}
+
+	if err := a.Statement.Check(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *SignedDomainAttestation) Check() error {
+	if a == nil {
+		return errors.New("missing signed domain attestation")
+	}
+
+	if err := a.Attestation.Check(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *UpdateDecision) Check() error {
+	if d == nil {
+		return errors.New("missing update decision")
+	}
+
+	return nil
+}
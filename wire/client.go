@@ -151,6 +151,19 @@ func (c *KeyTreeClient) Submit(update *SignedEntry) error {
 	return c.client.Post("/keytree/submit", update, &reply)
 }
 
+// Check asks the server whether it would accept update, without submitting
+// it.
+func (c *KeyTreeClient) Check(update *SignedEntry) (*UpdateDecision, error) {
+	var reply UpdateDecision
+	if err := c.client.Post("/keytree/check", update, &reply); err != nil {
+		return nil, err
+	}
+	if err := reply.Check(); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
 func (c *KeyTreeClient) TrieNode(h crypto.Hash, depth int) (*TrieNode, error) {
 	var reply *TrieNode
 	if err := c.client.Get(fmt.Sprintf("/keytree/trienode?hash=%s&depth=%d", h, depth), &reply); err != nil {
@@ -215,6 +228,18 @@ func (c *KeyTreeClient) History(h crypto.Hash, since uint64) (*SignedEntry, erro
 	return reply, nil
 }
 
+// PolicyDigest fetches the digest of the server's update policy from its
+// status.
+func (c *KeyTreeClient) PolicyDigest() (crypto.Hash, error) {
+	var reply struct {
+		PolicyDigest crypto.Hash
+	}
+	if err := c.client.Get("/keytree/status", &reply); err != nil {
+		return crypto.EmptyHash, err
+	}
+	return reply.PolicyDigest, nil
+}
+
 type DKIMClient struct {
 	client *Client
 }
@@ -241,3 +266,54 @@ func (c *DKIMClient) Poll(req string) (*DKIMStatus, error) {
 	}
 	return &reply, nil
 }
+
+// Wait is like Poll, but the server waits until there are more than since
+// status messages or a proof, or until its poll timeout passes.
+func (c *DKIMClient) Wait(req string, since int) (*DKIMStatus, error) {
+	var reply DKIMStatus
+	if err := c.client.Get(fmt.Sprintf("/dkim/poll?email=%s&since=%d", req, since), &reply); err != nil {
+		return nil, err
+	}
+	if err := reply.Check(); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+type HTTPSClient struct {
+	client *Client
+}
+
+func NewHTTPSClient(host string) *HTTPSClient {
+	return &HTTPSClient{client: NewClient(host)}
+}
+
+func (c *HTTPSClient) Attest(req *HTTPSStatement) (*SignedHTTPSAttestation, error) {
+	var reply SignedHTTPSAttestation
+	if err := c.client.Post("/https/attest", req, &reply); err != nil {
+		return nil, err
+	}
+	if err := reply.Check(); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+type DomainClient struct {
+	client *Client
+}
+
+func NewDomainClient(host string) *DomainClient {
+	return &DomainClient{client: NewClient(host)}
+}
+
+func (c *DomainClient) Attest(req *DomainStatement) (*SignedDomainAttestation, error) {
+	var reply SignedDomainAttestation
+	if err := c.client.Post("/domain/attest", req, &reply); err != nil {
+		return nil, err
+	}
+	if err := reply.Check(); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
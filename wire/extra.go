@@ -10,13 +10,26 @@ func (e *Entry) SigningTypeName() string {
 	return "github.com/jellevandenhooff/keytree.Entry-0.4"
 }
 
+// ToLeaf returns the trie leaf for e. Tombstones have an empty EntryHash.
 func (e *Entry) ToLeaf() *TrieLeaf {
+	if e.Deleted {
+		return &TrieLeaf{
+			NameHash:  crypto.HashString(e.Name),
+			EntryHash: crypto.EmptyHash,
+		}
+	}
+
 	return &TrieLeaf{
 		NameHash:  crypto.HashString(e.Name),
 		EntryHash: e.Hash(),
 	}
 }
 
+// IsTombstone returns whether l removes its name from the trie.
+func (l *TrieLeaf) IsTombstone() bool {
+	return l.EntryHash == crypto.EmptyHash
+}
+
 func (e *Entry) Hash() crypto.Hash {
 	if e == nil {
 		return crypto.EmptyHash
@@ -39,10 +52,55 @@ func (e *Entry) Hash() crypto.Hash {
 
 	h.WriteUint64(e.Timestamp)
 	h.WriteBool(e.InRecovery)
+	// Only hash the flag for tombstones so existing entries keep their hash.
+	if e.Deleted {
+		h.WriteBool(e.Deleted)
+	}
+
+	// Likewise, only hash validity windows if there are any.
+	if len(e.KeyValidity) > 0 {
+		h.WriteString("validity")
+
+		names := make([]string, 0, len(e.KeyValidity))
+		for name := range e.KeyValidity {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		h.WriteUint64(uint64(len(names)))
+		for _, name := range names {
+			validity := e.KeyValidity[name]
+			h.WriteString(name)
+			h.WriteUint64(validity.NotBefore)
+			h.WriteUint64(validity.NotAfter)
+		}
+	}
 
 	return h.Sum()
 }
 
+func (v *Validity) Contains(t uint64) bool {
+	if v == nil {
+		return true
+	}
+	return (v.NotBefore == 0 || v.NotBefore <= t) && (v.NotAfter == 0 || t < v.NotAfter)
+}
+
+// IsKeyValidAt returns whether key name of e is valid at time t.
+func (e *Entry) IsKeyValidAt(name string, t uint64) bool {
+	return e.KeyValidity[name].Contains(t)
+}
+
+// ValidKeys returns the keys of e that are valid at time t.
+func (e *Entry) ValidKeys(t uint64) map[string]string {
+	keys := make(map[string]string)
+	for name, key := range e.Keys {
+		if e.IsKeyValidAt(name, t) {
+			keys[name] = key
+		}
+	}
+	return keys
+}
+
 func (r *Root) SigningTypeName() string {
 	return "github.com/jellevandenhooff/keytree.Root-0.1"
 }
@@ -53,3 +111,47 @@ func (r *Root) Hash() crypto.Hash {
 	h.WriteUint64(r.Timestamp)
 	return h.Sum()
 }
+
+func (k *DKIMKey) SigningTypeName() string {
+	return "github.com/jellevandenhooff/keytree.DKIMKey-0.1"
+}
+
+func (k *DKIMKey) Hash() crypto.Hash {
+	h := crypto.NewHasher()
+	h.WriteString(k.Hostname)
+	h.WriteUint64(uint64(len(k.Records)))
+	for _, record := range k.Records {
+		h.WriteString(record)
+	}
+	h.WriteUint64(k.Timestamp)
+	if k.DNSSECSigned || k.DNSSECValidated {
+		h.WriteString("dnssec")
+		h.WriteBool(k.DNSSECSigned)
+		h.WriteBool(k.DNSSECValidated)
+	}
+	return h.Sum()
+}
+
+func (a *HTTPSAttestation) SigningTypeName() string {
+	return "github.com/jellevandenhooff/keytree.HTTPSAttestation-0.1"
+}
+
+func (a *HTTPSAttestation) Hash() crypto.Hash {
+	h := crypto.NewHasher()
+	h.WriteString(a.Statement.Origin)
+	h.WriteString(a.Statement.Token)
+	h.WriteUint64(a.Timestamp)
+	return h.Sum()
+}
+
+func (a *DomainAttestation) SigningTypeName() string {
+	return "github.com/jellevandenhooff/keytree.DomainAttestation-0.1"
+}
+
+func (a *DomainAttestation) Hash() crypto.Hash {
+	h := crypto.NewHasher()
+	h.WriteString(a.Statement.Domain)
+	h.WriteString(a.Statement.Token)
+	h.WriteUint64(a.Timestamp)
+	return h.Sum()
+}
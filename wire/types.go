@@ -7,6 +7,17 @@ type Entry struct {
 	Keys       map[string]string
 	Timestamp  uint64
 	InRecovery bool
+	// A deleted entry is a tombstone: it removes the name from the trie, but
+	// stays in the history.
+	Deleted bool `json:",omitempty"`
+	// Optional validity windows for keys, by key name.
+	KeyValidity map[string]*Validity `json:",omitempty"`
+}
+
+// A Validity limits when a key may be used. Zero means unbounded.
+type Validity struct {
+	NotBefore uint64 `json:",omitempty"`
+	NotAfter  uint64 `json:",omitempty"`
 }
 
 type SignedEntry struct {
@@ -62,13 +73,112 @@ type DKIMStatement struct {
 
 type DKIMUpdate struct {
 	Statement  *DKIMStatement
+	ProofType  string `json:",omitempty"` // signature name for Proof; "dkim" if empty
 	Proof      string
 	Status     []string
 	Expiration uint64
 }
 
 type DKIMStatus struct {
+	ProofType  string `json:",omitempty"` // signature name for Proof; "dkim" if empty
 	Proof      string
 	Status     []string
 	Expiration uint64
 }
+
+// A DKIMKey records the DNS TXT records that held a DKIM key when an email was
+// verified.
+type DKIMKey struct {
+	Hostname  string
+	Records   []string
+	Timestamp uint64
+
+	// Whether the records came from a DNSSEC-signed zone, and whether they
+	// validated.
+	DNSSECSigned    bool `json:",omitempty"`
+	DNSSECValidated bool `json:",omitempty"`
+}
+
+type SignedDKIMKey struct {
+	Key       *DKIMKey
+	PublicKey string
+	Signature string
+}
+
+// A DKIMProof is the canonical headers of a verified email together with the
+// key that verified it, so the proof can be checked after the key rotates.
+type DKIMProof struct {
+	Headers string
+	Key     *SignedDKIMKey
+}
+
+// An SMIMEProof is the signed part of an S/MIME email and its detached
+// PKCS#7 signature.
+type SMIMEProof struct {
+	Content   string
+	Signature []byte
+}
+
+type HTTPSStatement struct {
+	Origin string
+	Token  string
+}
+
+type HTTPSAttestation struct {
+	Statement *HTTPSStatement
+	Timestamp uint64
+}
+
+type SignedHTTPSAttestation struct {
+	Attestation *HTTPSAttestation
+	PublicKey   string
+	Signature   string
+}
+
+type DomainStatement struct {
+	Domain string
+	Token  string
+}
+
+// A DomainAttestation records that a server saw a domain's TXT record holding
+// a token.
+type DomainAttestation struct {
+	Statement *DomainStatement
+	Timestamp uint64
+}
+
+type SignedDomainAttestation struct {
+	Attestation *DomainAttestation
+	PublicKey   string
+	Signature   string
+}
+
+type RuleOutcome struct {
+	Rule    string
+	Passed  bool
+	Failure string `json:",omitempty"`
+}
+
+type UpdateDecision struct {
+	// inputs
+	ValidSignatures          []string
+	ChangedKeys              []string
+	HadKeytreeKey            bool
+	HasValidKeytreeSignature bool
+	HasChangedKeytreeKey     bool
+	HasValidOwnershipProof   bool
+	OwnershipProofError      string `json:",omitempty"`
+	Threshold                int
+	HasGuardianQuorum        bool
+
+	RecoveryOverride             bool
+	CancelsRecovery              bool
+	OverrideSignatureRequirement bool
+
+	// evaluated rules, in order
+	Rules []*RuleOutcome
+
+	// outcome
+	Accepted bool
+	Error    string `json:",omitempty"`
+}